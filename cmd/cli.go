@@ -1,17 +1,23 @@
 package cmd
 
 import (
-	"strconv"
-	"time"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 
 	mapper "gitea.antoine-langlois.net/datahearth/config-mapper/internal"
 	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
 	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/git"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
+// ExitPackagesFailed is the exit code of "load --pkgs" when at least one package failed to install
+const ExitPackagesFailed = 3
+
 var rootCmd = &cobra.Command{
 	Use:   "config-mapper",
 	Short: "Manage your systems configuration",
@@ -40,6 +46,76 @@ var saveCmd = &cobra.Command{
 		 saved location based on your configuration file`,
 	Run: save,
 }
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Lock your packages versions",
+	Long: `Lock records the installed version of every declared package into a "packages.lock"
+		file inside your saved location`,
+	Run: lock,
+}
+var brewfileCmd = &cobra.Command{
+	Use:   "brewfile",
+	Short: "Convert brew packages from and to a Brewfile",
+	Long:  `Convert brew taps, formulae and casks from and to the Brewfile format used by "brew bundle"`,
+}
+var brewfileImportCmd = &cobra.Command{
+	Use:   "import <Brewfile>",
+	Short: "Convert a Brewfile into a brew configuration",
+	Args:  cobra.ExactArgs(1),
+	Run:   brewfileImport,
+}
+var brewfileExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Convert your brew configuration into a Brewfile",
+	Run:   brewfileExport,
+}
+var nixHomeCmd = &cobra.Command{
+	Use:   "nix-home",
+	Short: "Generate a home-manager module from your files",
+	Long: `Generate a home-manager module linking your configured files from your saved location,
+		to use config-mapper alongside home-manager`,
+	Run: nixHome,
+}
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the state of your saved location",
+	Long: `Status reports uncommitted changes and commits queued by offline saves, without
+		contacting the remote`,
+	Run: status,
+}
+var promoteCmd = &cobra.Command{
+	Use:   "promote <item>...",
+	Short: "Copy items from a branch into another one",
+	Long: `Promote copies saved items (paths relative to your saved location) from your host
+		branch into the main branch and pushes them`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  promote,
+}
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Mark known good states of your configuration",
+	Long: `Snapshots are annotated tags of your repository. Load one of them with
+		"load --ref <snapshot>"`,
+}
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create a snapshot of your saved location",
+	Args:  cobra.ExactArgs(1),
+	Run:   snapshotCreate,
+}
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List snapshots",
+	Run:   snapshotList,
+}
+var logCmd = &cobra.Command{
+	Use:   "log [path]",
+	Short: "Show the history of your configurations",
+	Long: `Log shows the commits changing a configured item, given by its system path
+		(E.g: ~/.zshrc), or all commits if no path is given`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  logHistory,
+}
 var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "install additional tools",
@@ -56,37 +132,94 @@ func init() {
 	rootCmd.AddCommand(loadCmd)
 	rootCmd.AddCommand(saveCmd)
 	rootCmd.AddCommand(installCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(brewfileCmd)
+	rootCmd.AddCommand(nixHomeCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(promoteCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(logCmd)
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	brewfileCmd.AddCommand(brewfileImportCmd)
+	brewfileCmd.AddCommand(brewfileExportCmd)
 
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "STDOUT will be more verbose")
 	rootCmd.PersistentFlags().StringP("configuration-file", "c", "", "location of configuration file")
+	rootCmd.PersistentFlags().Bool("offline", false, "use the saved location as is without contacting the remote")
+	rootCmd.PersistentFlags().Bool("verify-signatures", false, "reject remote commits not signed by a trusted key")
 	rootCmd.PersistentFlags().String("ssh-user", "", "SSH username to retrieve configuration file")
 	rootCmd.PersistentFlags().String("ssh-password", "", "SSH password to retrieve configuration file")
 	rootCmd.PersistentFlags().String("ssh-key", "", "SSH key to retrieve configuration file (if a passphrase is needed, use the \"CONFIG_MAPPER_PASS\" env variable")
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("configuration-file", rootCmd.PersistentFlags().Lookup("configuration-file"))
+	viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
+	viper.BindPFlag("verify-signatures", rootCmd.PersistentFlags().Lookup("verify-signatures"))
 	viper.BindPFlag("ssh-user", rootCmd.PersistentFlags().Lookup("ssh-user"))
 	viper.BindPFlag("ssh-password", rootCmd.PersistentFlags().Lookup("ssh-password"))
 	viper.BindPFlag("ssh-key", rootCmd.PersistentFlags().Lookup("ssh-key"))
 
+	initCmd.Flags().Bool("trust-host", false, "show the host key of the SSH repository and record it once confirmed")
+	viper.BindPFlag("init-trust-host", initCmd.Flags().Lookup("trust-host"))
+
 	loadCmd.Flags().Bool("disable-files", false, "files will be ignored")
 	loadCmd.Flags().Bool("disable-folders", false, "folders will be ignored")
+	loadCmd.Flags().Bool("disable-blocks", false, "blocks will be ignored")
 	loadCmd.Flags().Bool("pkgs", false, "packages will be installed")
 	loadCmd.Flags().StringSlice("exclude-pkg-managers", []string{}, "package managers to exclude (comma separated)")
+	loadCmd.Flags().Bool("locked", false, "combined with --pkgs to install packages versions from the lockfile")
+	loadCmd.Flags().Bool("prune", false, "combined with --pkgs to uninstall packages no more declared")
+	loadCmd.Flags().Bool("fail-fast", false, "combined with --pkgs to stop installing packages after the first failure")
+	loadCmd.Flags().Bool("keep-going", false, "combined with --pkgs to install all packages even if some fail (default)")
+	loadCmd.Flags().Int("keep-logs", 10, "combined with --pkgs to set how many runs output are kept")
+	loadCmd.Flags().String("ref", "", "load your configurations from a snapshot, branch or commit")
 	viper.BindPFlag("load-disable-files", loadCmd.Flags().Lookup("disable-files"))
 	viper.BindPFlag("load-disable-folders", loadCmd.Flags().Lookup("disable-folders"))
+	viper.BindPFlag("load-disable-blocks", loadCmd.Flags().Lookup("disable-blocks"))
 	viper.BindPFlag("load-enable-pkgs", loadCmd.Flags().Lookup("pkgs"))
 	viper.BindPFlag("exclude-pkg-managers", loadCmd.Flags().Lookup("exclude-pkg-managers"))
+	viper.BindPFlag("load-locked", loadCmd.Flags().Lookup("locked"))
+	viper.BindPFlag("load-prune", loadCmd.Flags().Lookup("prune"))
+	viper.BindPFlag("load-fail-fast", loadCmd.Flags().Lookup("fail-fast"))
+	viper.BindPFlag("load-keep-going", loadCmd.Flags().Lookup("keep-going"))
+	viper.BindPFlag("load-keep-logs", loadCmd.Flags().Lookup("keep-logs"))
+	viper.BindPFlag("load-ref", loadCmd.Flags().Lookup("ref"))
 
 	saveCmd.Flags().Bool("disable-files", false, "files will be ignored")
 	saveCmd.Flags().Bool("disable-folders", false, "folders will be ignored")
+	saveCmd.Flags().Bool("disable-blocks", false, "blocks will be ignored")
 	saveCmd.Flags().BoolP("push", "p", false, "new configurations will be committed and pushed")
-	saveCmd.Flags().StringP("message", "m", strconv.FormatInt(time.Now().Unix(), 10), "combined with --push to set a commit message")
+	saveCmd.Flags().StringP("message", "m", "", "combined with --push to set a commit message (DEFAULT: generated from \"storage.git.commit-template\")")
 	saveCmd.Flags().Bool("disable-index", false, "configuration index will not be updated")
+	saveCmd.Flags().Bool("pkgs", false, "installed packages will be written into \"packages.yml\"")
+	saveCmd.Flags().Bool("pkgs-diff", false, "combined with --pkgs to only show the difference between installed and declared packages")
+	saveCmd.Flags().String("branch", "", "branch to save your configurations into (DEFAULT: \"storage.git.branch\")")
 	viper.BindPFlag("save-disable-files", saveCmd.Flags().Lookup("disable-files"))
 	viper.BindPFlag("save-disable-folders", saveCmd.Flags().Lookup("disable-folders"))
+	viper.BindPFlag("save-disable-blocks", saveCmd.Flags().Lookup("disable-blocks"))
 	viper.BindPFlag("push", saveCmd.Flags().Lookup("push"))
 	viper.BindPFlag("disable-index-update", saveCmd.Flags().Lookup("disable-index"))
 	viper.BindPFlag("message", saveCmd.Flags().Lookup("message"))
+	viper.BindPFlag("save-enable-pkgs", saveCmd.Flags().Lookup("pkgs"))
+	viper.BindPFlag("save-pkgs-diff", saveCmd.Flags().Lookup("pkgs-diff"))
+	viper.BindPFlag("save-branch", saveCmd.Flags().Lookup("branch"))
+
+	promoteCmd.Flags().String("from", "", "branch to copy items from (DEFAULT: \"storage.git.branch\")")
+	promoteCmd.Flags().String("to", "main", "branch to copy items into")
+	viper.BindPFlag("promote-from", promoteCmd.Flags().Lookup("from"))
+	viper.BindPFlag("promote-to", promoteCmd.Flags().Lookup("to"))
+
+	snapshotCreateCmd.Flags().StringP("message", "m", "", "description of the snapshot")
+	viper.BindPFlag("snapshot-message", snapshotCreateCmd.Flags().Lookup("message"))
+
+	logCmd.Flags().BoolP("patch", "p", false, "show the changes of each commit")
+	viper.BindPFlag("log-patch", logCmd.Flags().Lookup("patch"))
+
+	brewfileCmd.PersistentFlags().StringP("output", "o", "", "write the result into a file instead of STDOUT")
+	viper.BindPFlag("brewfile-output", brewfileCmd.PersistentFlags().Lookup("output"))
+
+	nixHomeCmd.Flags().StringP("output", "o", "", "write the module into a file instead of STDOUT")
+	viper.BindPFlag("nix-home-output", nixHomeCmd.Flags().Lookup("output"))
 }
 
 func Execute() {
@@ -95,20 +228,65 @@ func Execute() {
 	}
 }
 
+// openRepository opens the storage repository and pulls remote changes unless offline.
+//
+// Pull conflicts are reported with the system paths of the conflicted items.
+func openRepository(c configuration.Configuration, offline bool) git.RepositoryActions {
+	if viper.GetBool("verify-signatures") {
+		c.Storage.Git.Signing.VerifySignatures = true
+	}
+
+	r, err := git.NewRepository(c.Storage.Git, c.Storage.Path, offline)
+	if err == nil {
+		if r.Offline() && !offline {
+			log.Warn("remote is unreachable, using the saved location as is")
+		}
+		return r
+	}
+
+	var conflict *git.ConflictError
+	if !errors.As(err, &conflict) {
+		log.Fatal("failed to open repository", "path", c.Storage.Path, "err", err)
+	}
+
+	storage, _ := misc.AbsolutePath(c.Storage.Path)
+	log.Error("failed to pull remote changes because of conflicts", "strategy", conflict.Strategy)
+	for _, p := range conflict.Paths {
+		if systemPath := mapper.SystemPath(c, p); systemPath != "" {
+			log.Error("conflicted item", "path", p, "system-path", systemPath)
+		} else {
+			log.Error("conflicted file", "path", p)
+		}
+	}
+	fmt.Println("next steps:")
+	for _, s := range conflict.NextSteps(storage) {
+		fmt.Printf("  - %s\n", s)
+	}
+	os.Exit(1)
+
+	return nil
+}
+
 func save(cmd *cobra.Command, args []string) {
 	var c configuration.Configuration
-	if err := viper.Unmarshal(&c); err != nil {
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
 		log.Fatal("failed to decode configuration", "err", err)
 	}
 
+	if b := viper.GetString("save-branch"); b != "" {
+		c.Storage.Git.Branch = b
+	}
+
+	// * the index is read once the right branch is checked out
+	r := openRepository(c, viper.GetBool("offline"))
+
 	indexer, err := mapper.NewIndexer(c.Storage.Path)
 	if err != nil {
 		log.Fatal("failed to open the indexer", "err", err)
 	}
 
-	r, err := git.NewRepository(c.Storage.Git, c.Storage.Path)
-	if err != nil {
-		log.Fatal("failed to open repository", "path", c.Storage.Path, "err", err)
+	if err := mapper.RunHooks(c.Hooks, configuration.HookPreSave, "CONFIG_MAPPER_ACTION=save"); err != nil {
+		log.Fatal("pre-save hook failed", "err", err)
 	}
 
 	el := mapper.NewItemsActions(nil, c.Storage.Path, r, indexer)
@@ -119,6 +297,9 @@ func save(cmd *cobra.Command, args []string) {
 	if !viper.GetBool("save-disable-folders") {
 		el.AddItems(c.Folders)
 	}
+	if !viper.GetBool("save-disable-blocks") {
+		el.AddBlocks(c.Blocks)
+	}
 
 	el.Action("save")
 
@@ -126,29 +307,85 @@ func save(cmd *cobra.Command, args []string) {
 		log.Fatal("failed to clean repository", "err", err)
 	}
 
+	if viper.GetBool("save-enable-pkgs") {
+		captured := mapper.CapturePackages(c.PackageManagers)
+		if viper.GetBool("save-pkgs-diff") {
+			captured.Diff(c.PackageManagers)
+		} else if err := captured.Write(c.Storage.Path); err != nil {
+			log.Fatal("failed to write captured packages", "err", err)
+		}
+	}
+
 	if viper.GetBool("push") {
 		log.Info("pushing changes...")
 
 		if err := r.PushChanges(viper.GetString("message"), indexer.Lines(), indexer.RemovedLines()); err != nil {
-			log.Fatal("failed to push changes to repository", "err", err)
+			switch {
+			case errors.Is(err, git.NoErrNothingToCommit):
+				log.Info("nothing changed, no commit created")
+			case errors.Is(err, git.NoErrQueued):
+				log.Info("offline, commit will be pushed on the next online run")
+			default:
+				log.Fatal("failed to push changes to repository", "err", err)
+			}
 		}
 	}
+
+	if err := mapper.RunHooks(c.Hooks, configuration.HookPostSave, "CONFIG_MAPPER_ACTION=save"); err != nil {
+		log.Error("post-save hook failed", "err", err)
+	}
 }
 
 func load(cmd *cobra.Command, args []string) {
 	var c configuration.Configuration
-	if err := viper.Unmarshal(&c); err != nil {
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
 		log.Fatal("failed to decode configuration", "err", err)
 	}
 
-	i, err := mapper.NewIndexer(c.Storage.Path)
+	r := openRepository(c, viper.GetBool("offline"))
+
+	// * items are loaded from a copy of the revision, the worktree is left untouched
+	cleanup := func() {}
+	if ref := viper.GetString("load-ref"); ref != "" {
+		dir, err := os.MkdirTemp("", "config-mapper-ref-")
+		if err != nil {
+			log.Fatal("failed to create temporary directory", "err", err)
+		}
+		// * log.Fatal and os.Exit skip deferred calls, the copy is removed before exiting
+		cleanup = func() { os.RemoveAll(dir) }
+
+		if err := r.ExtractRef(ref, dir); err != nil {
+			cleanup()
+			log.Fatal("failed to extract revision", "ref", ref, "err", err)
+		}
+
+		log.Info("loading configurations from revision", "ref", ref)
+		c.Storage.Path = dir
+		viper.Set("storage.location", dir)
+	}
+
+	err := loadItems(c, r)
+	cleanup()
+
+	var ie *mapper.InstallError
+	if errors.As(err, &ie) {
+		log.Error("failed to install packages", "failures", len(ie.Report.Failures()))
+		os.Exit(ExitPackagesFailed)
+	}
 	if err != nil {
-		log.Fatal("failed to open the indexer", "err", err)
+		log.Fatal(err)
 	}
+}
 
-	r, err := git.NewRepository(c.Storage.Git, c.Storage.Path)
+// loadItems loads items and packages, it returns an *mapper.InstallError if packages failed to install
+func loadItems(c configuration.Configuration, r git.RepositoryActions) error {
+	i, err := mapper.NewIndexer(c.Storage.Path)
 	if err != nil {
-		log.Fatal("failed to open repository", "path", c.Storage.Path, "err", err)
+		return fmt.Errorf("failed to open the indexer: %w", err)
+	}
+
+	if err := mapper.RunHooks(c.Hooks, configuration.HookPreLoad, "CONFIG_MAPPER_ACTION=load"); err != nil {
+		return fmt.Errorf("pre-load hook failed: %w", err)
 	}
 
 	el := mapper.NewItemsActions(nil, c.Storage.Path, r, i)
@@ -159,27 +396,308 @@ func load(cmd *cobra.Command, args []string) {
 	if !viper.GetBool("load-disable-folders") {
 		el.AddItems(c.Folders)
 	}
+	if !viper.GetBool("load-disable-blocks") {
+		el.AddBlocks(c.Blocks)
+	}
 
-	el.Action("load")
+	changedFiles := el.Action("load")
+	changed := len(changedFiles) > 0
 
+	var ie *mapper.InstallError
 	if viper.GetBool("load-enable-pkgs") {
-		if err := mapper.InstallPackages(c.PackageManagers); err != nil {
-			log.Fatal(err)
+		if viper.GetBool("load-fail-fast") && viper.GetBool("load-keep-going") {
+			return errors.New("--fail-fast and --keep-going can't be used together")
+		}
+
+		var lock mapper.Lockfile
+		if viper.GetBool("load-locked") {
+			lock, err = mapper.ReadLockfile(c.Storage.Path)
+			if err != nil {
+				return fmt.Errorf("failed to read lockfile: %w", err)
+			}
+		}
+
+		installed, installErr := mapper.InstallPackages(c.PackageManagers, lock, len(c.Hooks.OnChange) > 0)
+		if installErr != nil && !errors.As(installErr, &ie) {
+			return installErr
+		}
+		changed = changed || installed
+
+		if viper.GetBool("load-prune") && (ie == nil || !viper.GetBool("load-fail-fast")) {
+			if err := mapper.PrunePackages(c.PackageManagers); err != nil {
+				return fmt.Errorf("failed to prune packages: %w", err)
+			}
+		}
+	}
+
+	if err := mapper.RunHooks(c.Hooks, configuration.HookPostLoad, "CONFIG_MAPPER_ACTION=load"); err != nil {
+		log.Error("post-load hook failed", "err", err)
+	}
+	if changed {
+		env := []string{"CONFIG_MAPPER_ACTION=load", fmt.Sprintf("CONFIG_MAPPER_CHANGED_FILES=%s", strings.Join(changedFiles, "\n"))}
+		if err := mapper.RunHooks(c.Hooks, configuration.HookOnChange, env...); err != nil {
+			log.Error("on-change hook failed", "err", err)
 		}
 	}
+
+	if ie != nil {
+		return ie
+	}
+	return nil
+}
+
+func status(cmd *cobra.Command, args []string) {
+	var c configuration.Configuration
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
+		log.Fatal("failed to decode configuration", "err", err)
+	}
+
+	s, err := openRepository(c, true).Status()
+	if err != nil {
+		log.Fatal("failed to retrieve repository status", "err", err)
+	}
+
+	fmt.Printf("branch: %s\n", s.Branch)
+	fmt.Printf("uncommitted changes: %d\n", s.Changes)
+	fmt.Printf("queued commits: %d\n", s.Queued)
+	if s.Queued > 0 {
+		fmt.Println("queued commits are pushed on the next online run")
+	}
+}
+
+func promote(cmd *cobra.Command, args []string) {
+	var c configuration.Configuration
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
+		log.Fatal("failed to decode configuration", "err", err)
+	}
+
+	from := viper.GetString("promote-from")
+	if from == "" {
+		if c.Storage.Git.Branch == "" {
+			log.Fatal("no branch to promote from, use --from or set \"storage.git.branch\"")
+		}
+
+		var err error
+		if from, err = git.ResolveBranch(c.Storage.Git.Branch); err != nil {
+			log.Fatal("failed to resolve branch", "err", err)
+		}
+	}
+	to := viper.GetString("promote-to")
+	if from == to {
+		log.Fatal("can't promote items into the same branch", "branch", from)
+	}
+
+	r := openRepository(c, viper.GetBool("offline"))
+
+	log.Info("promoting items", "from", from, "to", to, "items", args)
+	if err := r.Promote(from, to, args); err != nil {
+		switch {
+		case errors.Is(err, git.NoErrNothingToCommit):
+			log.Info("items are already up to date", "branch", to)
+		case errors.Is(err, git.NoErrQueued):
+			log.Info("offline, promotion will be pushed on the next online run")
+		default:
+			log.Fatal("failed to promote items", "err", err)
+		}
+		return
+	}
+
+	log.Info("items promoted", "branch", to)
+}
+
+func snapshotCreate(cmd *cobra.Command, args []string) {
+	var c configuration.Configuration
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
+		log.Fatal("failed to decode configuration", "err", err)
+	}
+
+	r := openRepository(c, viper.GetBool("offline"))
+	if err := r.CreateSnapshot(args[0], viper.GetString("snapshot-message")); err != nil {
+		if !errors.Is(err, git.NoErrQueued) {
+			log.Fatal("failed to create snapshot", "err", err)
+		}
+		log.Info("offline, snapshot will be pushed with \"git push --tags\"", "path", c.Storage.Path)
+	}
+
+	log.Info("snapshot created. Load it with \"load --ref\"", "name", args[0])
+}
+
+func snapshotList(cmd *cobra.Command, args []string) {
+	var c configuration.Configuration
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
+		log.Fatal("failed to decode configuration", "err", err)
+	}
+
+	snapshots, err := openRepository(c, viper.GetBool("offline")).Snapshots()
+	if err != nil {
+		log.Fatal("failed to list snapshots", "err", err)
+	}
+
+	for _, s := range snapshots {
+		fmt.Printf("%s\t%s\t%s\t%s\t%s\n", s.Name, s.Commit, s.Date.Format("2006-01-02 15:04"), s.Tagger, strings.SplitN(strings.TrimSpace(s.Description), "\n", 2)[0])
+	}
+}
+
+func logHistory(cmd *cobra.Command, args []string) {
+	var c configuration.Configuration
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
+		log.Fatal("failed to decode configuration", "err", err)
+	}
+
+	var p string
+	if len(args) > 0 {
+		if p = mapper.StoragePath(c, args[0]); p == "" {
+			log.Fatal("path doesn't belong to any configured item", "path", args[0])
+		}
+	}
+
+	entries, err := openRepository(c, viper.GetBool("offline")).Log(p, viper.GetBool("log-patch"))
+	if err != nil {
+		log.Fatal("failed to read history", "err", err)
+	}
+
+	for _, e := range entries {
+		fmt.Printf("commit %s\n", e.Hash)
+		fmt.Printf("Author: %s <%s>\n", e.Author, e.Email)
+		if e.Host != "" {
+			fmt.Printf("Host:   %s\n", e.Host)
+		}
+		fmt.Printf("Date:   %s\n\n", e.Date.Format("Mon Jan 2 15:04:05 2006 -0700"))
+		fmt.Printf("    %s\n\n", e.Subject)
+		fmt.Print(e.Stats.String())
+		if e.Patch != "" {
+			fmt.Printf("\n%s", e.Patch)
+		}
+		fmt.Println()
+	}
+}
+
+func lock(cmd *cobra.Command, args []string) {
+	var c configuration.Configuration
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
+		log.Fatal("failed to decode configuration", "err", err)
+	}
+
+	openRepository(c, viper.GetBool("offline"))
+
+	log.Info("locking packages versions...")
+
+	if err := mapper.LockPackages(c.PackageManagers).Write(c.Storage.Path); err != nil {
+		log.Fatal("failed to write lockfile", "err", err)
+	}
+
+	log.Info("lockfile written. Use \"save --push\" to share it", "path", c.Storage.Path)
+}
+
+// trustHost shows the host key of the repository SSH server and records it in the known hosts file once confirmed
+func trustHost(c configuration.Configuration) {
+	hostKey, err := git.ScanHostKey(c.Storage.Git)
+	if err != nil {
+		log.Fatal("failed to verify host key", "err", err)
+	}
+	if hostKey.Known {
+		log.Info("host key already trusted", "host", hostKey.Host, "fingerprint", hostKey.Fingerprint)
+		return
+	}
+
+	fmt.Printf("%s presents the %s key %s\n", hostKey.Host, hostKey.Type, hostKey.Fingerprint)
+	if !misc.Confirm("Trust this host key?") {
+		log.Fatal("host key not trusted", "host", hostKey.Host)
+	}
+
+	if err := git.TrustHostKey(c.Storage.Git, hostKey); err != nil {
+		log.Fatal("failed to record host key", "err", err)
+	}
+	log.Info("host key trusted", "host", hostKey.Host, "fingerprint", hostKey.Fingerprint)
 }
 
 func initCommand(cmd *cobra.Command, args []string) {
 	var c configuration.Configuration
-	if err := viper.Unmarshal(&c); err != nil {
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
 		log.Fatal("failed to decode configuration", "err", err)
 	}
 
+	if viper.GetBool("init-trust-host") {
+		trustHost(c)
+	}
+
 	log.Info("initializing config-mapper folder from configuration...")
 
-	if _, err := git.NewRepository(c.Storage.Git, c.Storage.Path); err != nil {
-		log.Fatal("failed to initialize folder", "err", err)
-	}
+	openRepository(c, viper.GetBool("offline"))
 
 	log.Info("repository initialized", "path", viper.GetString("storage.location"))
 }
+
+func brewfileImport(cmd *cobra.Command, args []string) {
+	f, err := os.Open(args[0])
+	if err != nil {
+		log.Fatal("failed to open Brewfile", "err", err)
+	}
+	defer f.Close()
+
+	brew, err := mapper.ImportBrewfile(f)
+	if err != nil {
+		log.Fatal("failed to read Brewfile", "err", err)
+	}
+
+	b, err := mapper.BrewYAML(brew)
+	if err != nil {
+		log.Fatal("failed to encode brew configuration", "err", err)
+	}
+
+	if o := viper.GetString("brewfile-output"); o != "" {
+		if err := os.WriteFile(o, b, 0644); err != nil {
+			log.Fatal("failed to write brew configuration", "err", err)
+		}
+		return
+	}
+
+	fmt.Print(string(b))
+}
+
+func brewfileExport(cmd *cobra.Command, args []string) {
+	var c configuration.Configuration
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
+		log.Fatal("failed to decode configuration", "err", err)
+	}
+
+	w := os.Stdout
+	if o := viper.GetString("brewfile-output"); o != "" {
+		f, err := os.Create(o)
+		if err != nil {
+			log.Fatal("failed to create Brewfile", "err", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := mapper.ExportBrewfile(c.PackageManagers.Brew, w); err != nil {
+		log.Fatal("failed to write Brewfile", "err", err)
+	}
+}
+
+func nixHome(cmd *cobra.Command, args []string) {
+	var c configuration.Configuration
+	if err := viper.Unmarshal(&c, configuration.DecodeHook()); err != nil {
+		log.Fatal("failed to decode configuration", "err", err)
+	}
+
+	storage, err := misc.AbsolutePath(c.Storage.Path)
+	if err != nil {
+		log.Fatal("failed to resolve storage location", "err", err)
+	}
+
+	b, err := mapper.NixHomeFragment(c.Files, storage)
+	if err != nil {
+		log.Fatal("failed to generate home-manager module", "err", err)
+	}
+
+	if o := viper.GetString("nix-home-output"); o != "" {
+		if err := os.WriteFile(o, b, 0644); err != nil {
+			log.Fatal("failed to write home-manager module", "err", err)
+		}
+		return
+	}
+
+	fmt.Print(string(b))
+}
@@ -0,0 +1,207 @@
+package mapper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+)
+
+const (
+	aptSourcesDir  = "/etc/apt/sources.list.d"
+	aptKeyringsDir = "/etc/apt/keyrings"
+	// aptSourceHeader marks the sources written by config-mapper
+	aptSourceHeader = "# managed by config-mapper"
+	keyFetchTimeout = 30 * time.Second
+)
+
+var ErrAptSourceName = errors.New("apt source requires a name")
+
+// setupAptSources writes apt sources and their keyrings onto the system.
+//
+// "apt update" is only run once and if at least one source or keyring changed.
+// Keyring paths can contain "$LOCATION" which is replaced by the storage location.
+func setupAptSources(sources []configuration.AptSource, storage string, out io.Writer) error {
+	changed, err := removeStaleAptSources(sources, out)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sources {
+		if s.Name == "" {
+			return ErrAptSourceName
+		}
+
+		keyring, keyChanged, err := writeAptKeyring(s, storage)
+		if err != nil {
+			return fmt.Errorf("failed to write keyring for apt source %s: %v", s.Name, err)
+		}
+
+		sourceChanged, err := writeIfChanged(path.Join(aptSourcesDir, s.Name+".sources"), aptSourceContent(s, keyring))
+		if err != nil {
+			return fmt.Errorf("failed to write apt source %s: %v", s.Name, err)
+		}
+
+		if keyChanged || sourceChanged {
+			fmt.Fprintf(out, "apt source %s updated\n", s.Name)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	cmd := exec.Command("sudo", "apt", "update")
+	cmd.Stdout, cmd.Stderr = out, out
+
+	return cmd.Run()
+}
+
+// writeAptKeyring retrieves the source keyring and writes it in "/etc/apt/keyrings".
+//
+// It returns the keyring location on the system and whether it changed.
+func writeAptKeyring(s configuration.AptSource, storage string) (string, bool, error) {
+	var key []byte
+	var err error
+	switch {
+	case s.SignedBy.URL != "":
+		key, err = fetchKey(s.SignedBy.URL)
+	case s.SignedBy.Path != "":
+		var p string
+		p, err = misc.AbsolutePath(strings.Replace(s.SignedBy.Path, "$LOCATION", storage, 1))
+		if err != nil {
+			return "", false, err
+		}
+		key, err = os.ReadFile(p)
+	default:
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	// * apt expects the ".asc" extension for armored keys and ".gpg" for binary ones
+	ext := ".gpg"
+	if bytes.HasPrefix(bytes.TrimSpace(key), []byte("-----BEGIN PGP")) {
+		ext = ".asc"
+	}
+
+	keyring := path.Join(aptKeyringsDir, s.Name+ext)
+	changed, err := writeIfChanged(keyring, key)
+	if err != nil {
+		return "", false, err
+	}
+
+	return keyring, changed, nil
+}
+
+// removeStaleAptSources removes the sources written by config-mapper which are no more declared, along with their keyring.
+// Sources not written by config-mapper are never removed.
+func removeStaleAptSources(sources []configuration.AptSource, out io.Writer) (bool, error) {
+	declared := map[string]bool{}
+	for _, s := range sources {
+		declared[s.Name+".sources"] = true
+	}
+
+	entries, err := os.ReadDir(aptSourcesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	removed := false
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".sources") || declared[e.Name()] {
+			continue
+		}
+
+		p := path.Join(aptSourcesDir, e.Name())
+		content, err := os.ReadFile(p)
+		if err != nil || !bytes.HasPrefix(content, []byte(aptSourceHeader+"\n")) {
+			continue
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			keyring := strings.TrimSpace(strings.TrimPrefix(line, "Signed-By:"))
+			// * only keyrings written by config-mapper are removed
+			if strings.HasPrefix(line, "Signed-By:") && path.Dir(keyring) == aptKeyringsDir {
+				if err := misc.RemoveFileSudo(keyring); err != nil {
+					return removed, fmt.Errorf("failed to remove keyring %s: %v", keyring, err)
+				}
+			}
+		}
+		if err := misc.RemoveFileSudo(p); err != nil {
+			return removed, fmt.Errorf("failed to remove apt source %s: %v", p, err)
+		}
+
+		fmt.Fprintf(out, "apt source %s removed\n", strings.TrimSuffix(e.Name(), ".sources"))
+		removed = true
+	}
+
+	return removed, nil
+}
+
+func fetchKey(url string) ([]byte, error) {
+	client := &http.Client{Timeout: keyFetchTimeout}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", res.StatusCode, url)
+	}
+
+	return io.ReadAll(res.Body)
+}
+
+// aptSourceContent renders an apt source in the deb822 format
+func aptSourceContent(s configuration.AptSource, keyring string) []byte {
+	types := s.Types
+	if len(types) == 0 {
+		types = []string{"deb"}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n", aptSourceHeader)
+	fmt.Fprintf(&b, "Types: %s\n", strings.Join(types, " "))
+	fmt.Fprintf(&b, "URIs: %s\n", strings.Join(s.URIs, " "))
+	fmt.Fprintf(&b, "Suites: %s\n", strings.Join(s.Suites, " "))
+	if len(s.Components) > 0 {
+		fmt.Fprintf(&b, "Components: %s\n", strings.Join(s.Components, " "))
+	}
+	if len(s.Architectures) > 0 {
+		fmt.Fprintf(&b, "Architectures: %s\n", strings.Join(s.Architectures, " "))
+	}
+	if keyring != "" {
+		fmt.Fprintf(&b, "Signed-By: %s\n", keyring)
+	}
+
+	return b.Bytes()
+}
+
+// writeIfChanged writes a system file only if its content differs and returns whether it was written
+func writeIfChanged(p string, data []byte) (bool, error) {
+	current, err := os.ReadFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+
+	return true, misc.WriteFileSudo(p, data, 0644)
+}
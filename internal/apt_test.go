@@ -0,0 +1,98 @@
+package mapper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+)
+
+func TestAptSourceContent(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		source  configuration.AptSource
+		keyring string
+		want    string
+	}{
+		{
+			name: "defaults",
+			source: configuration.AptSource{
+				Name:   "docker",
+				URIs:   []string{"https://download.docker.com/linux/ubuntu"},
+				Suites: []string{"jammy"},
+			},
+			want: aptSourceHeader + "\nTypes: deb\nURIs: https://download.docker.com/linux/ubuntu\nSuites: jammy\n",
+		},
+		{
+			name: "all fields",
+			source: configuration.AptSource{
+				Name:          "docker",
+				Types:         []string{"deb", "deb-src"},
+				URIs:          []string{"https://download.docker.com/linux/ubuntu"},
+				Suites:        []string{"jammy", "noble"},
+				Components:    []string{"stable"},
+				Architectures: []string{"amd64", "arm64"},
+			},
+			keyring: "/etc/apt/keyrings/docker.asc",
+			want: aptSourceHeader + "\nTypes: deb deb-src\nURIs: https://download.docker.com/linux/ubuntu\nSuites: jammy noble\n" +
+				"Components: stable\nArchitectures: amd64 arm64\nSigned-By: /etc/apt/keyrings/docker.asc\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(aptSourceContent(tc.source, tc.keyring)); got != tc.want {
+				t.Errorf("aptSourceContent() =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFetchKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/key.asc" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("-----BEGIN PGP PUBLIC KEY BLOCK-----"))
+	}))
+	defer server.Close()
+
+	key, err := fetchKey(server.URL + "/key.asc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) != "-----BEGIN PGP PUBLIC KEY BLOCK-----" {
+		t.Errorf("fetchKey() = %q", key)
+	}
+
+	if _, err := fetchKey(server.URL + "/missing"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+}
+
+func TestWriteIfChanged(t *testing.T) {
+	p := path.Join(t.TempDir(), "sources.list.d", "docker.sources")
+
+	for _, tc := range []struct {
+		name string
+		data string
+		want bool
+	}{
+		{"new file", "a", true},
+		{"same content", "a", false},
+		{"changed content", "b", true},
+	} {
+		changed, err := writeIfChanged(p, []byte(tc.data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != tc.want {
+			t.Errorf("%s: writeIfChanged() = %t, want %t", tc.name, changed, tc.want)
+		}
+		if got, _ := os.ReadFile(p); string(got) != tc.data {
+			t.Errorf("%s: file content = %q, want %q", tc.name, got, tc.data)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/charmbracelet/log"
+)
+
+const (
+	blockPresent = "present"
+	blockAbsent  = "absent"
+)
+
+var (
+	ErrBlockID           = errors.New("block id must be set and can't contain spaces")
+	ErrBlockState        = errors.New("block state must be either \"present\" or \"absent\"")
+	ErrBlockUnterminated = errors.New("block end marker not found")
+	ErrBlockNotFound     = errors.New("block not found in file")
+)
+
+// blockMarkers returns the lines delimiting a block
+func blockMarkers(b configuration.Block) (string, string) {
+	comment := b.Comment
+	if comment == "" {
+		comment = "#"
+	}
+
+	return fmt.Sprintf("%s BEGIN config-mapper %s", comment, b.ID), fmt.Sprintf("%s END config-mapper %s", comment, b.ID)
+}
+
+func validateBlock(b configuration.Block) error {
+	if b.ID == "" || strings.ContainsAny(b.ID, " \t\n") {
+		return ErrBlockID
+	}
+	if b.State != "" && b.State != blockPresent && b.State != blockAbsent {
+		return ErrBlockState
+	}
+
+	return nil
+}
+
+// findBlock returns the indexes of the block markers lines. begin is -1 if the block isn't found.
+func findBlock(lines []string, b configuration.Block) (int, int, error) {
+	begin, end := blockMarkers(b)
+	for i, l := range lines {
+		if strings.TrimSpace(l) != begin {
+			continue
+		}
+
+		for j := i + 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == end {
+				return i, j, nil
+			}
+		}
+
+		return -1, -1, fmt.Errorf("%w: %s", ErrBlockUnterminated, end)
+	}
+
+	return -1, -1, nil
+}
+
+// replaceBlock inserts, updates or removes (when content is nil) a block inside a file content.
+//
+// The rest of the file is kept untouched. A new block is appended at the end of the file.
+func replaceBlock(file string, b configuration.Block, content *string) (string, error) {
+	lines := []string{}
+	if file != "" {
+		lines = strings.Split(strings.TrimSuffix(file, "\n"), "\n")
+	}
+
+	begin, end, err := findBlock(lines, b)
+	if err != nil {
+		return "", err
+	}
+
+	block := []string{}
+	if content != nil {
+		beginMarker, endMarker := blockMarkers(b)
+		block = append(block, beginMarker)
+		if c := strings.TrimSuffix(*content, "\n"); c != "" {
+			block = append(block, strings.Split(c, "\n")...)
+		}
+		block = append(block, endMarker)
+	}
+
+	if begin == -1 {
+		lines = append(lines, block...)
+	} else {
+		lines = append(append(append([]string{}, lines[:begin]...), block...), lines[end+1:]...)
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// extractBlock returns the content of a block inside a file content, without its markers
+func extractBlock(file string, b configuration.Block) (string, error) {
+	lines := strings.Split(strings.TrimSuffix(file, "\n"), "\n")
+
+	begin, end, err := findBlock(lines, b)
+	if err != nil {
+		return "", err
+	}
+	if begin == -1 {
+		return "", ErrBlockNotFound
+	}
+
+	content := strings.Join(lines[begin+1:end], "\n")
+	if content != "" {
+		content += "\n"
+	}
+
+	return content, nil
+}
+
+// saveBlock saves the content of a managed block inside the configured saved location.
+//
+// Returns the relative block location from the saved location to write the index, or an empty string
+// if the block hasn't been saved.
+func (e *Items) saveBlock(src, dst string, b configuration.Block) string {
+	if err := validateBlock(b); err != nil {
+		log.Error("invalid block", "id", b.ID, "err", err)
+		return ""
+	}
+	if b.State == blockAbsent {
+		log.Info("block is absent, nothing to save", "id", b.ID)
+		return ""
+	}
+
+	file, err := os.ReadFile(src)
+	if err != nil {
+		log.Error("failed to read file holding the block", "id", b.ID, "path", src, "err", err)
+		return ""
+	}
+
+	content, err := extractBlock(string(file), b)
+	if err != nil {
+		log.Error("failed to extract block", "id", b.ID, "path", src, "err", err)
+		return ""
+	}
+
+	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+		log.Error("failed to create directory architecture for destination path", "path", path.Dir(dst), "err", err)
+		return ""
+	}
+	if err := os.WriteFile(dst, []byte(content), 0644); err != nil {
+		log.Error("failed to save block", "id", b.ID, "destination", dst, "err", err)
+		return ""
+	}
+
+	p, err := misc.AbsolutePath(e.storage)
+	if err != nil {
+		log.Error("failed resolve absolute path from configuration storage", "err", err)
+		return ""
+	}
+
+	return strings.ReplaceAll(dst, p+"/", "")
+}
+
+// loadBlock inserts, updates or removes a managed block inside a system file.
+//
+// The file is only written if the block changed. It's written through "sudo" if needed (E.g: "/etc/hosts").
+func (e *Items) loadBlock(src, dst string, b configuration.Block) ([]string, bool) {
+	if err := validateBlock(b); err != nil {
+		log.Error("invalid block", "id", b.ID, "err", err)
+		return nil, false
+	}
+
+	var content *string
+	if b.State != blockAbsent {
+		c, err := os.ReadFile(src)
+		if err != nil {
+			log.Error("failed to read saved block", "id", b.ID, "path", src, "err", err)
+			return nil, false
+		}
+		s := string(c)
+		content = &s
+	}
+
+	perms := os.FileMode(0644)
+	file, err := os.ReadFile(dst)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error("failed to read file holding the block", "id", b.ID, "path", dst, "err", err)
+			return nil, false
+		}
+		// * nothing to remove from a missing file
+		if content == nil {
+			return []string{}, true
+		}
+	} else if s, err := os.Stat(dst); err == nil {
+		perms = s.Mode()
+	}
+
+	updated, err := replaceBlock(string(file), b, content)
+	if err != nil {
+		log.Error("failed to update block", "id", b.ID, "path", dst, "err", err)
+		return nil, false
+	}
+	if updated == string(file) {
+		return []string{}, true
+	}
+
+	if err := misc.WriteFileSudo(dst, []byte(updated), perms); err != nil {
+		log.Error("failed to write file holding the block", "id", b.ID, "path", dst, "err", err)
+		return nil, false
+	}
+
+	return []string{dst}, true
+}
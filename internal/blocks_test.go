@@ -0,0 +1,96 @@
+package mapper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+)
+
+func TestFindBlock(t *testing.T) {
+	block := configuration.Block{ID: "aliases"}
+	for _, tc := range []struct {
+		name       string
+		file       string
+		block      configuration.Block
+		begin, end int
+		err        error
+	}{
+		{"missing", "export PATH\n", block, -1, -1, nil},
+		{"found", "a\n# BEGIN config-mapper aliases\nalias ll='ls -l'\n# END config-mapper aliases\nb", block, 1, 3, nil},
+		{"indented markers", "  # BEGIN config-mapper aliases\n  # END config-mapper aliases", block, 0, 1, nil},
+		{"other id", "# BEGIN config-mapper path\n# END config-mapper path", block, -1, -1, nil},
+		{"custom comment", "-- BEGIN config-mapper aliases\n-- END config-mapper aliases", configuration.Block{ID: "aliases", Comment: "--"}, 0, 1, nil},
+		{"unterminated", "# BEGIN config-mapper aliases\nalias ll='ls -l'", block, -1, -1, ErrBlockUnterminated},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			begin, end, err := findBlock(strings.Split(tc.file, "\n"), tc.block)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v, want %v", err, tc.err)
+			}
+			if begin != tc.begin || end != tc.end {
+				t.Errorf("findBlock() = (%d, %d), want (%d, %d)", begin, end, tc.begin, tc.end)
+			}
+		})
+	}
+}
+
+func TestReplaceBlock(t *testing.T) {
+	block := configuration.Block{ID: "aliases"}
+	content := func(s string) *string { return &s }
+
+	for _, tc := range []struct {
+		name    string
+		file    string
+		content *string
+		want    string
+	}{
+		{
+			name:    "append to empty file",
+			file:    "",
+			content: content("alias ll='ls -l'\n"),
+			want:    "# BEGIN config-mapper aliases\nalias ll='ls -l'\n# END config-mapper aliases\n",
+		},
+		{
+			name:    "append after content",
+			file:    "export PATH",
+			content: content("alias ll='ls -l'"),
+			want:    "export PATH\n# BEGIN config-mapper aliases\nalias ll='ls -l'\n# END config-mapper aliases\n",
+		},
+		{
+			name:    "update in place",
+			file:    "a\n# BEGIN config-mapper aliases\nold\n# END config-mapper aliases\nb\n",
+			content: content("new\n"),
+			want:    "a\n# BEGIN config-mapper aliases\nnew\n# END config-mapper aliases\nb\n",
+		},
+		{
+			name:    "empty content",
+			file:    "a\n",
+			content: content(""),
+			want:    "a\n# BEGIN config-mapper aliases\n# END config-mapper aliases\n",
+		},
+		{
+			name:    "remove",
+			file:    "a\n# BEGIN config-mapper aliases\nold\n# END config-mapper aliases\nb\n",
+			content: nil,
+			want:    "a\nb\n",
+		},
+		{
+			name:    "remove the only block",
+			file:    "# BEGIN config-mapper aliases\nold\n# END config-mapper aliases\n",
+			content: nil,
+			want:    "",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := replaceBlock(tc.file, block, tc.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("replaceBlock() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
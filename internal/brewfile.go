@@ -0,0 +1,134 @@
+package mapper
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	brewfileEntry = regexp.MustCompile(`^(\w+)\s+"([^"]+)"(?:\s*,\s*"([^"]+)")?(.*)$`)
+	brewfileArgs  = regexp.MustCompile(`args:\s*\[([^\]]*)\]`)
+)
+
+// ExportBrewfile writes the brew configuration in the Brewfile format used by "brew bundle"
+func ExportBrewfile(brew configuration.Brew, w io.Writer) error {
+	for _, tap := range brew.Taps {
+		line := fmt.Sprintf("tap %q", tap.Name)
+		if tap.URL != "" {
+			line = fmt.Sprintf("%s, %q", line, tap.URL)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range brew.Formulas {
+		line := fmt.Sprintf("brew %q", f.String())
+		if len(f.Options) > 0 {
+			args := make([]string, len(f.Options))
+			for i, o := range f.Options {
+				args[i] = fmt.Sprintf("%q", strings.TrimPrefix(o, "--"))
+			}
+			line = fmt.Sprintf("%s, args: [%s]", line, strings.Join(args, ", "))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range brew.Casks {
+		if _, err := fmt.Fprintf(w, "cask %q\n", c.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportBrewfile parses a Brewfile into a brew configuration.
+//
+// Entries not handled by config-mapper (E.g: "mas", "vscode") are skipped with a warning.
+func ImportBrewfile(r io.Reader) (configuration.Brew, error) {
+	brew := configuration.Brew{
+		Taps:     []configuration.Tap{},
+		Formulas: []configuration.Package{},
+		Casks:    []configuration.Package{},
+	}
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := strings.TrimSpace(s.Text())
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		m := brewfileEntry.FindStringSubmatch(l)
+		if m == nil {
+			log.Warn("unsupported Brewfile line", "line", l)
+			continue
+		}
+
+		switch m[1] {
+		case "tap":
+			brew.Taps = append(brew.Taps, configuration.Tap{Name: m[2], URL: m[3]})
+		case "brew":
+			// * "@" is part of versioned formula names (E.g: python@3.11)
+			f := configuration.Package{Name: m[2]}
+			if args := brewfileArgs.FindStringSubmatch(m[4]); args != nil {
+				for _, a := range strings.Split(args[1], ",") {
+					if a = strings.Trim(strings.TrimSpace(a), `"'`); a != "" {
+						f.Options = append(f.Options, "--"+a)
+					}
+				}
+			}
+			brew.Formulas = append(brew.Formulas, f)
+		case "cask":
+			brew.Casks = append(brew.Casks, configuration.Package{Name: m[2]})
+		default:
+			log.Warn("unsupported Brewfile entry", "entry", m[1], "name", m[2])
+		}
+	}
+
+	return brew, s.Err()
+}
+
+// BrewYAML returns the brew configuration as a "package-managers" YAML section.
+//
+// Packages without options are written as plain strings.
+func BrewYAML(brew configuration.Brew) ([]byte, error) {
+	taps := []interface{}{}
+	for _, t := range brew.Taps {
+		if t.URL == "" {
+			taps = append(taps, t.Name)
+		} else {
+			taps = append(taps, t)
+		}
+	}
+
+	formulas := []interface{}{}
+	for _, f := range brew.Formulas {
+		if len(f.Options) == 0 {
+			formulas = append(formulas, f.String())
+		} else {
+			formulas = append(formulas, yaml.MapSlice{{Key: "name", Value: f.String()}, {Key: "options", Value: f.Options}})
+		}
+	}
+
+	casks := []string{}
+	for _, c := range brew.Casks {
+		casks = append(casks, c.Name)
+	}
+
+	return yaml.Marshal(yaml.MapSlice{{Key: "package-managers", Value: yaml.MapSlice{{Key: "brew", Value: yaml.MapSlice{
+		{Key: "taps", Value: taps},
+		{Key: "formulas", Value: formulas},
+		{Key: "casks", Value: casks},
+	}}}}})
+}
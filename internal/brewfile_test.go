@@ -0,0 +1,95 @@
+package mapper
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+)
+
+func TestImportBrewfile(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		brewfile string
+		want     configuration.Brew
+	}{
+		{
+			name:     "taps",
+			brewfile: "tap \"homebrew/cask-fonts\"\ntap \"user/repo\", \"https://host/user/homebrew-repo.git\"\n",
+			want: configuration.Brew{
+				Taps: []configuration.Tap{
+					{Name: "homebrew/cask-fonts"},
+					{Name: "user/repo", URL: "https://host/user/homebrew-repo.git"},
+				},
+			},
+		},
+		{
+			name:     "versioned formulae are kept whole",
+			brewfile: "brew \"python@3.11\"\nbrew \"openssl@3\"\n",
+			want: configuration.Brew{
+				Formulas: []configuration.Package{{Name: "python@3.11"}, {Name: "openssl@3"}},
+			},
+		},
+		{
+			name:     "formula arguments",
+			brewfile: "brew \"vim\", args: [\"with-lua\", \"HEAD\"]\nbrew \"bat\", restart_service: true\n",
+			want: configuration.Brew{
+				Formulas: []configuration.Package{
+					{Name: "vim", Options: []string{"--with-lua", "--HEAD"}},
+					{Name: "bat"},
+				},
+			},
+		},
+		{
+			name:     "casks",
+			brewfile: "cask \"firefox\"\ncask \"temurin@17\"\n",
+			want: configuration.Brew{
+				Casks: []configuration.Package{{Name: "firefox"}, {Name: "temurin@17"}},
+			},
+		},
+		{
+			name:     "unsupported entries and comments are skipped",
+			brewfile: "# comment\n\nmas \"Xcode\", id: 497799835\nvscode \"golang.go\"\ncask_args appdir: \"~/Applications\"\nbrew \"git\"\n",
+			want: configuration.Brew{
+				Formulas: []configuration.Package{{Name: "git"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ImportBrewfile(strings.NewReader(tc.brewfile))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := configuration.Brew{Taps: []configuration.Tap{}, Formulas: []configuration.Package{}, Casks: []configuration.Package{}}
+			want.Taps = append(want.Taps, tc.want.Taps...)
+			want.Formulas = append(want.Formulas, tc.want.Formulas...)
+			want.Casks = append(want.Casks, tc.want.Casks...)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ImportBrewfile() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestBrewfileRoundTrip(t *testing.T) {
+	brew := configuration.Brew{
+		Taps:     []configuration.Tap{{Name: "user/repo", URL: "https://host/user/homebrew-repo.git"}},
+		Formulas: []configuration.Package{{Name: "python@3.11"}, {Name: "vim", Options: []string{"--with-lua"}}},
+		Casks:    []configuration.Package{{Name: "firefox"}},
+	}
+
+	var b bytes.Buffer
+	if err := ExportBrewfile(brew, &b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ImportBrewfile(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, brew) {
+		t.Errorf("round trip = %+v, want %+v", got, brew)
+	}
+}
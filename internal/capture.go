@@ -0,0 +1,158 @@
+package mapper
+
+import (
+	"fmt"
+	"os"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v2"
+)
+
+const packagesFileName = "packages.yml"
+
+// CapturedPackages holds packages installed by the user for each configured package manager
+type CapturedPackages struct {
+	order    []string
+	packages map[string][]string
+	// brew is written with its taps, formulae and casks sections
+	brew configuration.Brew
+}
+
+// CapturePackages queries each package manager from the installation order for user-installed packages
+func CapturePackages(c configuration.PkgManagers) *CapturedPackages {
+	captured := &CapturedPackages{
+		order:    []string{},
+		packages: map[string][]string{},
+	}
+
+	for _, pkgManager := range c.InstallationOrder {
+		// * runtimes aren't packages
+		if pkgManager == toolchainsEntry {
+			continue
+		}
+
+		bin, err := resolveBinary(pkgManager)
+		if err != nil {
+			log.Error(err, "package-manager", pkgManager)
+			continue
+		}
+
+		env := []string{"CONFIG_MAPPER_ACTION=save", fmt.Sprintf("CONFIG_MAPPER_PACKAGE_MANAGER=%s", pkgManager)}
+		if err := RunHooks(c.Hooks[pkgManager], configuration.HookPreSave, env...); err != nil {
+			log.Error("package manager skipped", "package-manager", pkgManager, "err", err)
+			continue
+		}
+
+		var pkgs []string
+		if pkgManager == "brew" {
+			captured.brew, err = userBrew(bin)
+			for _, p := range append(captured.brew.Formulas, captured.brew.Casks...) {
+				pkgs = append(pkgs, p.Name)
+			}
+		} else {
+			pkgs, err = userPackages(pkgManager, bin)
+		}
+		if err != nil {
+			log.Error("failed to retrieve installed packages", "package-manager", pkgManager, "err", err)
+			continue
+		}
+
+		if err := RunHooks(c.Hooks[pkgManager], configuration.HookPostSave, env...); err != nil {
+			log.Error("package manager hook failed", "package-manager", pkgManager, "err", err)
+		}
+
+		captured.order = append(captured.order, pkgManager)
+		captured.packages[pkgManager] = pkgs
+		log.Info("packages captured", "package-manager", pkgManager, "count", len(pkgs))
+	}
+
+	return captured
+}
+
+// Write writes captured packages into a "packages.yml" file at the root of the storage location.
+//
+// The file uses the "package-managers" section format so it can be copied into the configuration file.
+func (cp *CapturedPackages) Write(storage string) error {
+	p, err := misc.AbsolutePath(fmt.Sprintf("%s/%s", storage, packagesFileName))
+	if err != nil {
+		return err
+	}
+
+	managers := yaml.MapSlice{}
+	for _, pkgManager := range cp.order {
+		if pkgManager == "brew" {
+			managers = append(managers, yaml.MapItem{Key: pkgManager, Value: brewSections(cp.brew)})
+			continue
+		}
+		managers = append(managers, yaml.MapItem{Key: pkgManager, Value: cp.packages[pkgManager]})
+	}
+
+	b, err := yaml.Marshal(yaml.MapSlice{{Key: "package-managers", Value: managers}})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p, append([]byte("# generated by \"config-mapper save --pkgs\". DO NOT EDIT\n"), b...), 0644)
+}
+
+// brewSections returns the "brew" section with its taps, formulae and casks written as plain names
+func brewSections(brew configuration.Brew) yaml.MapSlice {
+	names := func(pkgs []configuration.Package) []string {
+		l := []string{}
+		for _, p := range pkgs {
+			l = append(l, p.Name)
+		}
+		return l
+	}
+
+	taps := []string{}
+	for _, t := range brew.Taps {
+		taps = append(taps, t.Name)
+	}
+
+	return yaml.MapSlice{
+		{Key: "taps", Value: taps},
+		{Key: "formulas", Value: names(brew.Formulas)},
+		{Key: "casks", Value: names(brew.Casks)},
+	}
+}
+
+// Diff prints packages installed but not declared (+) and packages declared but not installed (-)
+func (cp *CapturedPackages) Diff(c configuration.PkgManagers) {
+	for _, pkgManager := range cp.order {
+		declared, err := declaredPackages(c, pkgManager)
+		if err != nil {
+			log.Error(err, "package-manager", pkgManager)
+			continue
+		}
+
+		installed := map[string]bool{}
+		for _, p := range cp.packages[pkgManager] {
+			installed[p] = true
+		}
+		declaredNames := map[string]bool{}
+		for _, p := range declared {
+			declaredNames[p.Name] = true
+		}
+
+		fmt.Printf("%s:\n", pkgManager)
+		changes := 0
+		for _, p := range cp.packages[pkgManager] {
+			if !declaredNames[p] {
+				fmt.Printf("  + %s\n", p)
+				changes++
+			}
+		}
+		for _, p := range declared {
+			if !installed[p.Name] {
+				fmt.Printf("  - %s\n", p.Name)
+				changes++
+			}
+		}
+		if changes == 0 {
+			fmt.Println("  ✔️ up to date")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package mapper
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+)
+
+func TestUserPackages(t *testing.T) {
+	for _, tc := range []struct {
+		pkgManager string
+		script     string
+		want       []string
+	}{
+		{
+			pkgManager: "pip",
+			script:     `printf 'black==23.1.0\nrequests==2.28.1\n'`,
+			want:       []string{"black", "requests"},
+		},
+		{
+			pkgManager: "npm",
+			script:     `echo '{"dependencies":{"typescript":{"version":"5.0.4"},"npm":{"version":"9.6.7"},"corepack":{"version":"0.18.0"}}}'`,
+			want:       []string{"typescript"},
+		},
+		{
+			pkgManager: "cargo",
+			script:     `printf 'ripgrep v13.0.0:\n    rg\nbat v0.22.1:\n    bat\n'`,
+			want:       []string{"bat", "ripgrep"},
+		},
+	} {
+		t.Run(tc.pkgManager, func(t *testing.T) {
+			got, err := userPackages(tc.pkgManager, fakeCommand(t, tc.script))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("userPackages(%s) = %v, want %v", tc.pkgManager, got, tc.want)
+			}
+		})
+	}
+
+	if _, err := userPackages("unknown", "unknown"); err != ErrPkgManagerUnsupported {
+		t.Errorf("got %v, want %v", err, ErrPkgManagerUnsupported)
+	}
+}
+
+func TestUserBrew(t *testing.T) {
+	bin := fakeCommand(t, `case "$*" in
+tap) printf 'homebrew/core\nhashicorp/tap\n' ;;
+"leaves --installed-on-request") printf 'bat\nterraform\n' ;;
+"list --cask") echo firefox ;;
+esac
+`)
+
+	got, err := userBrew(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := configuration.Brew{
+		Taps:     []configuration.Tap{{Name: "hashicorp/tap"}},
+		Formulas: []configuration.Package{{Name: "bat"}, {Name: "terraform"}},
+		Casks:    []configuration.Package{{Name: "firefox"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("userBrew() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCapturedPackagesWrite(t *testing.T) {
+	cp := &CapturedPackages{
+		order:    []string{"pip", "brew", "cargo"},
+		packages: map[string][]string{"pip": {"black"}, "brew": {"bat", "firefox"}, "cargo": {}},
+		brew: configuration.Brew{
+			Taps:     []configuration.Tap{{Name: "hashicorp/tap"}},
+			Formulas: []configuration.Package{{Name: "bat"}},
+			Casks:    []configuration.Package{{Name: "firefox"}},
+		},
+	}
+
+	storage := t.TempDir()
+	if err := cp.Write(storage); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path.Join(storage, packagesFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# generated by "config-mapper save --pkgs". DO NOT EDIT
+package-managers:
+  pip:
+  - black
+  brew:
+    taps:
+    - hashicorp/tap
+    formulas:
+    - bat
+    casks:
+    - firefox
+  cargo: []
+`
+	if string(got) != want {
+		t.Errorf("packages.yml =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -1,15 +1,50 @@
 package configuration
 
+import "time"
+
 type Configuration struct {
 	Storage         Storage      `mapstructure:"storage" yaml:"storage"`
 	Files           []OSLocation `mapstructure:"files" yaml:"files"`
 	Folders         []OSLocation `mapstructure:"folders" yaml:"folders"`
+	Blocks          []Block      `mapstructure:"blocks" yaml:"blocks"`
 	PackageManagers PkgManagers  `mapstructure:"package-managers" yaml:"package-managers"`
+	Hooks           Hooks        `mapstructure:"hooks" yaml:"hooks"`
 }
 
 type OSLocation struct {
 	Darwin string `mapstructure:"darwin" yaml:"darwin"`
 	Linux  string `mapstructure:"linux" yaml:"linux"`
+	Hooks  Hooks  `mapstructure:"hooks" yaml:"hooks,omitempty"`
+	// Merge deep-merges the saved file into the system file instead of copying it (json, yaml, toml)
+	Merge string `mapstructure:"merge" yaml:"merge,omitempty"`
+	// Keys are the key paths kept when saving a merged file (DEFAULT: all keys)
+	Keys []string `mapstructure:"keys" yaml:"keys,omitempty"`
+	// ExcludeKeys are the key paths never saved nor loaded for a merged file
+	ExcludeKeys []string `mapstructure:"exclude-keys" yaml:"exclude-keys,omitempty"`
+}
+
+// Block is a text region managed by config-mapper inside a system file shared with other tools.
+//
+// The region is delimited by "# BEGIN config-mapper <id>" and "# END config-mapper <id>" markers.
+type Block struct {
+	OSLocation `mapstructure:",squash" yaml:",inline"`
+	ID         string `mapstructure:"id" yaml:"id"`
+	// State is either "present" (DEFAULT) or "absent" to remove the block from the system file
+	State string `mapstructure:"state" yaml:"state,omitempty"`
+	// Comment is the markers prefix (DEFAULT: "#")
+	Comment string `mapstructure:"comment" yaml:"comment,omitempty"`
+}
+
+// Hooks are shell commands run before or after an action
+type Hooks struct {
+	PreSave  []string `mapstructure:"pre-save" yaml:"pre-save,omitempty"`
+	PostSave []string `mapstructure:"post-save" yaml:"post-save,omitempty"`
+	PreLoad  []string `mapstructure:"pre-load" yaml:"pre-load,omitempty"`
+	PostLoad []string `mapstructure:"post-load" yaml:"post-load,omitempty"`
+	// OnChange commands run after a "load" which modified the system
+	OnChange []string `mapstructure:"on-change" yaml:"on-change,omitempty"`
+	// Timeout of each command (DEFAULT: 5m)
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout,omitempty"`
 }
 
 type Storage struct {
@@ -22,12 +57,50 @@ type Git struct {
 	Name      string    `mapstructure:"name" yaml:"name"`
 	Email     string    `mapstructure:"email" yaml:"email"`
 	BasicAuth BasicAuth `mapstructure:"basic-auth" yaml:"basic-auth"`
-	SSH       interface{}       `mapstructure:"ssh" yaml:"ssh"`
+	// SSH is either a list of keys or SshOptions. Keys are tried in order before the ssh-agent keys.
+	SSH interface{} `mapstructure:"ssh" yaml:"ssh"`
+	// DisableSSHAgent stops offering the keys of the agent listening on SSH_AUTH_SOCK
+	DisableSSHAgent bool `mapstructure:"disable-ssh-agent" yaml:"disable-ssh-agent"`
+	// CommitTemplate is the Go template of generated commit messages (E.g: "save({{.Host}}): {{.Summary}}")
+	CommitTemplate string `mapstructure:"commit-template" yaml:"commit-template"`
+	// PullStrategy is either "ff-only" (DEFAULT), "merge", "rebase" or "skip"
+	PullStrategy string `mapstructure:"pull-strategy" yaml:"pull-strategy"`
+	// Branch is checked out before any action and can be a template (E.g: "hosts/{{.Hostname}}")
+	Branch  string  `mapstructure:"branch" yaml:"branch"`
+	Signing Signing `mapstructure:"signing" yaml:"signing"`
 }
 
+// Signing signs commits with either a GPG or an SSH key
+type Signing struct {
+	GPG GPGSigning `mapstructure:"gpg" yaml:"gpg"`
+	SSH SSHSigning `mapstructure:"ssh" yaml:"ssh"`
+	// TrustedKeys are public key files (armored GPG keys or SSH "authorized_keys" format)
+	// accepted when verifying signatures. The signing key is always trusted.
+	TrustedKeys []string `mapstructure:"trusted-keys" yaml:"trusted-keys"`
+	// VerifySignatures rejects remote commits not signed by a trusted key when pulling
+	VerifySignatures bool `mapstructure:"verify-signatures" yaml:"verify-signatures"`
+}
+type GPGSigning struct {
+	// KeyID selects a key of the key file (E.g: "3AA5C34371567BD2"), the first one is used if empty
+	KeyID      string `mapstructure:"key-id" yaml:"key-id"`
+	KeyFile    string `mapstructure:"key-file" yaml:"key-file"`
+	Passphrase string `mapstructure:"passphrase" yaml:"passphrase"`
+}
+type SSHSigning struct {
+	PrivateKey string `mapstructure:"private-key" yaml:"private-key"`
+	Passphrase string `mapstructure:"passphrase" yaml:"passphrase"`
+}
 type BasicAuth struct {
 	Username string `mapstructure:"username" yaml:"username"`
 	Password string `mapstructure:"password" yaml:"password"`
+	// PasswordEnv is the environment variable holding the password
+	PasswordEnv string `mapstructure:"password-env" yaml:"password-env"`
+	// PasswordCommand prints the password on its first line (E.g: "pass show git/token")
+	PasswordCommand string `mapstructure:"password-command" yaml:"password-command"`
+	// Netrc is the netrc file used when no password is configured (DEFAULT: $NETRC or ~/.netrc)
+	Netrc string `mapstructure:"netrc" yaml:"netrc"`
+	// DisableCredentialHelper stops asking "git credential fill" when no password is configured
+	DisableCredentialHelper bool `mapstructure:"disable-credential-helper" yaml:"disable-credential-helper"`
 }
 
 type Ssh struct {
@@ -35,13 +108,92 @@ type Ssh struct {
 	Passphrase string `mapstructure:"passphrase" yaml:"passphrase"`
 }
 
+// SshOptions is the map form of the SSH configuration, a single key and/or a list of keys with the host key verification
+type SshOptions struct {
+	Ssh  `mapstructure:",squash" yaml:",inline"`
+	Keys []Ssh `mapstructure:"keys" yaml:"keys"`
+	// KnownHosts is the known hosts file (DEFAULT: ~/.ssh/known_hosts)
+	KnownHosts string `mapstructure:"known-hosts" yaml:"known-hosts"`
+	// HostKeyPolicy is either "strict" (DEFAULT), "accept-new" or "fingerprint"
+	HostKeyPolicy string `mapstructure:"host-key-policy" yaml:"host-key-policy"`
+	// Fingerprints are the SHA256 fingerprints accepted by the "fingerprint" policy (E.g: "SHA256:+DiY3wvvV6TuJJhbpZisF/zLDA0zPMSvHdkr4UvCOqU")
+	Fingerprints []string `mapstructure:"fingerprints" yaml:"fingerprints"`
+}
+
 type PkgManagers struct {
-	InstallationOrder []string `mapstructure:"installation-order" yaml:"installation-order"`
-	Brew              []string `mapstructure:"brew" yaml:"brew"`
-	Apt               []string `mapstructure:"apt" yaml:"apt"`
-	Cargo             []string `mapstructure:"cargo" yaml:"cargo"`
-	Pip               []string `mapstructure:"pip" yaml:"pip"`
-	Npm               []string `mapstructure:"npm" yaml:"npm"`
-	Go                []string `mapstructure:"go" yaml:"go"`
-	Nala              []string `mapstructure:"nala" yaml:"nala"`
+	InstallationOrder []string  `mapstructure:"installation-order" yaml:"installation-order"`
+	Brew              Brew      `mapstructure:"brew" yaml:"brew"`
+	Apt               Apt       `mapstructure:"apt" yaml:"apt"`
+	Cargo             []Package `mapstructure:"cargo" yaml:"cargo"`
+	Pip               []Package `mapstructure:"pip" yaml:"pip"`
+	Npm               []Package `mapstructure:"npm" yaml:"npm"`
+	Go                []Package `mapstructure:"go" yaml:"go"`
+	Nala              []Package `mapstructure:"nala" yaml:"nala"`
+	Pipx              []Package `mapstructure:"pipx" yaml:"pipx"`
+	Uv                []Package `mapstructure:"uv" yaml:"uv"`
+	Pnpm              []Package `mapstructure:"pnpm" yaml:"pnpm"`
+	Yarn              []Package `mapstructure:"yarn" yaml:"yarn"`
+	// Nix holds flake references installed with "nix profile" (E.g: "nixpkgs#ripgrep")
+	Nix        []Package  `mapstructure:"nix" yaml:"nix"`
+	Toolchains Toolchains `mapstructure:"toolchains" yaml:"toolchains"`
+	// DependsOn lists package managers to install before a package manager (E.g: "cargo: [toolchains]")
+	DependsOn map[string][]string `mapstructure:"depends-on" yaml:"depends-on"`
+	// Hooks are run around each package manager installation (E.g: "brew: {pre-load: [brew update]}")
+	Hooks map[string]Hooks `mapstructure:"hooks" yaml:"hooks"`
+}
+
+type Package struct {
+	Name    string `mapstructure:"name" yaml:"name"`
+	Version string `mapstructure:"version" yaml:"version"`
+	// Options are passed to the package manager when installing the package (brew formulae only)
+	Options []string `mapstructure:"options" yaml:"options,omitempty"`
+}
+
+type Brew struct {
+	Taps     []Tap     `mapstructure:"taps" yaml:"taps"`
+	Formulas []Package `mapstructure:"formulas" yaml:"formulas"`
+	Casks    []Package `mapstructure:"casks" yaml:"casks"`
+}
+
+type Tap struct {
+	Name string `mapstructure:"name" yaml:"name"`
+	URL  string `mapstructure:"url" yaml:"url,omitempty"`
+}
+
+type Apt struct {
+	Sources  []AptSource `mapstructure:"sources" yaml:"sources"`
+	Packages []Package   `mapstructure:"packages" yaml:"packages"`
+}
+
+// AptSource is a deb822 source entry written in "/etc/apt/sources.list.d/<name>.sources"
+type AptSource struct {
+	Name          string   `mapstructure:"name" yaml:"name"`
+	Types         []string `mapstructure:"types" yaml:"types"`
+	URIs          []string `mapstructure:"uris" yaml:"uris"`
+	Suites        []string `mapstructure:"suites" yaml:"suites"`
+	Components    []string `mapstructure:"components" yaml:"components"`
+	Architectures []string `mapstructure:"architectures" yaml:"architectures"`
+	SignedBy      AptKey   `mapstructure:"signed-by" yaml:"signed-by"`
+}
+
+// AptKey is a keyring retrieved either from an URL or from a file (can contain "$LOCATION")
+type AptKey struct {
+	URL  string `mapstructure:"url" yaml:"url"`
+	Path string `mapstructure:"path" yaml:"path"`
+}
+
+// Toolchains declares language runtimes installed before language package managers
+type Toolchains struct {
+	// Manager is the default version manager used for all runtimes (mise, asdf)
+	Manager string    `mapstructure:"manager" yaml:"manager"`
+	Go      Toolchain `mapstructure:"go" yaml:"go"`
+	Node    Toolchain `mapstructure:"node" yaml:"node"`
+	Python  Toolchain `mapstructure:"python" yaml:"python"`
+	Rust    Toolchain `mapstructure:"rust" yaml:"rust"`
+}
+
+type Toolchain struct {
+	Version string `mapstructure:"version" yaml:"version"`
+	// Manager overrides the default version manager (mise, asdf, rustup, pyenv, nvm)
+	Manager string `mapstructure:"manager" yaml:"manager"`
 }
@@ -0,0 +1,27 @@
+package configuration
+
+const (
+	HookPreSave  = "pre-save"
+	HookPostSave = "post-save"
+	HookPreLoad  = "pre-load"
+	HookPostLoad = "post-load"
+	HookOnChange = "on-change"
+)
+
+// Commands returns the commands of a hook event
+func (h Hooks) Commands(event string) []string {
+	switch event {
+	case HookPreSave:
+		return h.PreSave
+	case HookPostSave:
+		return h.PostSave
+	case HookPreLoad:
+		return h.PreLoad
+	case HookPostLoad:
+		return h.PostLoad
+	case HookOnChange:
+		return h.OnChange
+	default:
+		return nil
+	}
+}
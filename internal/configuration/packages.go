@@ -0,0 +1,136 @@
+package configuration
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+// DecodeHook returns the viper decoding option required to unmarshal a Configuration.
+//
+// It keeps viper's default hooks and adds support for packages, taps and toolchains written as a plain string
+// and for the "brew" and "apt" sections written as a list of packages.
+// Brew formulae and casks are never parsed as "name@version" as "@" is part of versioned formula names.
+func DecodeHook() viper.DecoderConfigOption {
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		packageHook,
+		packagesListHook,
+		brewHook,
+	))
+}
+
+// packageHook decodes a "name" or "name@version" string into a Package, a "name" string into a Tap
+// and a "version" string into a Toolchain.
+//
+// Toolchain versions written as numbers are accepted as well (E.g: "node: 20").
+func packageHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	switch f.Kind() {
+	case reflect.String:
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		if t == reflect.TypeOf(Toolchain{}) {
+			return Toolchain{Version: fmt.Sprint(data)}, nil
+		}
+		return data, nil
+	default:
+		return data, nil
+	}
+
+	switch t {
+	case reflect.TypeOf(Package{}):
+		return ParsePackage(data.(string)), nil
+	case reflect.TypeOf(Tap{}):
+		return Tap{Name: data.(string)}, nil
+	case reflect.TypeOf(Toolchain{}):
+		return Toolchain{Version: data.(string)}, nil
+	default:
+		return data, nil
+	}
+}
+
+// packagesListHook decodes a list of packages into brew formulae or apt packages
+func packagesListHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if f.Kind() != reflect.Slice {
+		return data, nil
+	}
+
+	switch t {
+	case reflect.TypeOf(Brew{}):
+		return map[string]interface{}{"formulas": data}, nil
+	case reflect.TypeOf(Apt{}):
+		return map[string]interface{}{"packages": data}, nil
+	default:
+		return data, nil
+	}
+}
+
+// brewHook keeps brew formulae and casks written as a plain string whole (E.g: "python@3.11" is a formula, not a version)
+func brewHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	if t != reflect.TypeOf(Brew{}) {
+		return data, nil
+	}
+
+	brew := map[string]interface{}{}
+	switch m := data.(type) {
+	case map[string]interface{}:
+		for k, v := range m {
+			brew[k] = v
+		}
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			brew[fmt.Sprint(k)] = v
+		}
+	default:
+		return data, nil
+	}
+
+	for _, k := range []string{"formulas", "casks"} {
+		entries, ok := brew[k].([]interface{})
+		if !ok {
+			continue
+		}
+
+		packages := make([]interface{}, len(entries))
+		for i, e := range entries {
+			packages[i] = e
+			if name, ok := e.(string); ok {
+				packages[i] = map[string]interface{}{"name": name}
+			}
+		}
+		brew[k] = packages
+	}
+
+	return brew, nil
+}
+
+// ParsePackage parses a package declared as "name" or "name@version".
+//
+// The version is only extracted when the declaration doesn't contain additional arguments
+// (E.g: "ripgrep --features pcre2") and a leading "@" is kept as part of the name
+// to support scoped packages (E.g: "@angular/cli@15.0.0").
+// Brew formulae must not be parsed with it (E.g: "python@3.11" is a formula name).
+func ParsePackage(s string) Package {
+	if strings.Contains(s, " ") {
+		return Package{Name: s}
+	}
+
+	i := strings.LastIndex(s, "@")
+	if i <= 0 {
+		return Package{Name: s}
+	}
+
+	return Package{Name: s[:i], Version: s[i+1:]}
+}
+
+// String returns the package declaration as "name" or "name@version"
+func (p Package) String() string {
+	if p.Version == "" {
+		return p.Name
+	}
+
+	return p.Name + "@" + p.Version
+}
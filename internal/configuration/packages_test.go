@@ -0,0 +1,105 @@
+package configuration
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParsePackage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want Package
+	}{
+		{"name only", "ripgrep", Package{Name: "ripgrep"}},
+		{"versioned", "ripgrep@13.0.0", Package{Name: "ripgrep", Version: "13.0.0"}},
+		{"scoped", "@angular/cli", Package{Name: "@angular/cli"}},
+		{"scoped and versioned", "@angular/cli@15.0.0", Package{Name: "@angular/cli", Version: "15.0.0"}},
+		{"arguments", "ripgrep --features pcre2", Package{Name: "ripgrep --features pcre2"}},
+		{"arguments with @", "tool@1.0 --locked", Package{Name: "tool@1.0 --locked"}},
+		{"last @ is the version", "pkg@tag@1.0", Package{Name: "pkg@tag", Version: "1.0"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ParsePackage(tc.in); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParsePackage(%q) = %+v, want %+v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHookBrew(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		yaml string
+		want Brew
+	}{
+		{
+			name: "versioned formula names are kept whole",
+			yaml: `
+brew:
+  taps:
+    - homebrew/cask-fonts
+  formulas:
+    - python@3.11
+    - name: openssl@3
+      options: [--HEAD]
+  casks:
+    - temurin@17
+`,
+			want: Brew{
+				Taps: []Tap{{Name: "homebrew/cask-fonts"}},
+				Formulas: []Package{
+					{Name: "python@3.11"},
+					{Name: "openssl@3", Options: []string{"--HEAD"}},
+				},
+				Casks: []Package{{Name: "temurin@17"}},
+			},
+		},
+		{
+			name: "list of formulae",
+			yaml: `
+brew:
+  - python@3.11
+  - bat
+`,
+			want: Brew{Formulas: []Package{{Name: "python@3.11"}, {Name: "bat"}}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := viper.New()
+			v.SetConfigType("yaml")
+			if err := v.ReadConfig(strings.NewReader(tc.yaml)); err != nil {
+				t.Fatal(err)
+			}
+
+			var c PkgManagers
+			if err := v.Unmarshal(&c, DecodeHook()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.Brew, tc.want) {
+				t.Errorf("brew = %+v, want %+v", c.Brew, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHookPackages(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader("npm:\n  - \"@angular/cli@15.0.0\"\n  - name: typescript\n    version: 5.0.0\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var c PkgManagers
+	if err := v.Unmarshal(&c, DecodeHook()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Package{{Name: "@angular/cli", Version: "15.0.0"}, {Name: "typescript", Version: "5.0.0"}}
+	if !reflect.DeepEqual(c.Npm, want) {
+		t.Errorf("npm = %+v, want %+v", c.Npm, want)
+	}
+}
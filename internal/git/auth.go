@@ -0,0 +1,246 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/mitchellh/mapstructure"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+var (
+	ErrSSHConfig = errors.New("git ssh configuration must be a key or a list of keys")
+	ErrSSHAuth   = errors.New("ssh authentication failed")
+)
+
+// sshAuth offers the configured keys in order, then the ssh-agent keys.
+// Keys are loaded when connecting and the tried methods are kept to explain authentication failures.
+type sshAuth struct {
+	gitssh.HostKeyCallbackHelper
+	user  string
+	keys  []configuration.Ssh
+	agent bool
+	// address and knownHosts select the host key algorithms known for the server, if known hosts are used
+	address    string
+	knownHosts string
+
+	mu        sync.Mutex
+	tried     []string
+	agentConn net.Conn
+}
+
+// newAuthMethod selects the authentication method from the repository URL: SSH keys for SSH URLs, basic auth for HTTP(S) URLs
+func newAuthMethod(config configuration.Git) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch endpoint.Protocol {
+	case "ssh":
+	case "http", "https":
+		return basicAuth(config.BasicAuth, endpoint)
+	default:
+		return nil, nil
+	}
+
+	options, err := sshOptions(config.SSH)
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := newHostKeyCallback(options)
+	if err != nil {
+		return nil, err
+	}
+
+	user := endpoint.User
+	if user == "" {
+		user = gitssh.DefaultUsername
+	}
+
+	auth := &sshAuth{
+		user:  user,
+		keys:  options.Keys,
+		agent: !config.DisableSSHAgent,
+	}
+	auth.HostKeyCallback = hostKeyCallback
+	if options.HostKeyPolicy != HostKeyFingerprint {
+		if auth.address, _, err = sshAddress(config.URL); err != nil {
+			return nil, err
+		}
+		if auth.knownHosts, err = knownHostsPath(options); err != nil {
+			return nil, err
+		}
+	}
+
+	return auth, nil
+}
+
+// sshOptions decodes the SSH configuration, either a list of keys or the options map.
+// The single key of the options map is tried before its list of keys.
+func sshOptions(config interface{}) (configuration.SshOptions, error) {
+	var options configuration.SshOptions
+	var keys []configuration.Ssh
+	switch c := config.(type) {
+	case nil:
+		return options, nil
+	case []interface{}:
+		for i, entry := range c {
+			var key configuration.Ssh
+			if err := mapstructure.Decode(entry, &key); err != nil {
+				return options, fmt.Errorf("failed to decode ssh configuration n°%d: %v", i, err)
+			}
+			keys = append(keys, key)
+		}
+	case map[string]interface{}, map[interface{}]interface{}:
+		if err := mapstructure.Decode(c, &options); err != nil {
+			return options, fmt.Errorf("failed to decode ssh configuration: %v", err)
+		}
+		keys = append([]configuration.Ssh{options.Ssh}, options.Keys...)
+	default:
+		return options, ErrSSHConfig
+	}
+
+	options.Keys = []configuration.Ssh{}
+	for i, key := range keys {
+		// * an empty key is the default configuration file value
+		if key.PrivateKey == "" && key.Passphrase == "" {
+			continue
+		}
+		if key.PrivateKey == "" {
+			return options, fmt.Errorf("ssh configuration n°%d has no private key", i)
+		}
+		options.Keys = append(options.Keys, key)
+	}
+
+	return options, nil
+}
+
+func (a *sshAuth) Name() string {
+	return "ssh-keys"
+}
+
+func (a *sshAuth) String() string {
+	return fmt.Sprintf("user: %s, name: %s", a.user, a.Name())
+}
+
+func (a *sshAuth) ClientConfig() (*ssh.ClientConfig, error) {
+	config := &ssh.ClientConfig{
+		User: a.user,
+		Auth: []ssh.AuthMethod{ssh.PublicKeysCallback(a.signers)},
+	}
+	if a.knownHosts != "" {
+		config.HostKeyAlgorithms = knownHostAlgorithms(a.knownHosts, a.address)
+	}
+
+	return a.SetHostKeyCallback(config)
+}
+
+// signers loads the configured keys and the ssh-agent keys. Keys failing to load are skipped.
+func (a *sshAuth) signers() ([]ssh.Signer, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.tried = []string{}
+	signers := []ssh.Signer{}
+	for _, key := range a.keys {
+		signer, err := loadSSHKey(key)
+		if err != nil {
+			a.tried = append(a.tried, fmt.Sprintf("key %s (%v)", key.PrivateKey, err))
+			continue
+		}
+		a.tried = append(a.tried, fmt.Sprintf("key %s (%s)", key.PrivateKey, ssh.FingerprintSHA256(signer.PublicKey())))
+		signers = append(signers, signer)
+	}
+
+	if a.agent {
+		agentSigners, err := a.agentSigners()
+		switch {
+		case err != nil:
+			a.tried = append(a.tried, fmt.Sprintf("ssh-agent (%v)", err))
+		case len(agentSigners) == 0:
+			a.tried = append(a.tried, "ssh-agent (no keys)")
+		default:
+			fingerprints := []string{}
+			for _, s := range agentSigners {
+				fingerprints = append(fingerprints, ssh.FingerprintSHA256(s.PublicKey()))
+			}
+			a.tried = append(a.tried, fmt.Sprintf("ssh-agent (%s)", strings.Join(fingerprints, ", ")))
+			signers = append(signers, agentSigners...)
+		}
+	}
+
+	return signers, nil
+}
+
+// agentSigners lists the keys of the agent listening on SSH_AUTH_SOCK.
+// The connection is opened once and kept open as the agent signs during the handshakes, it's reopened if it broke.
+func (a *sshAuth) agentSigners() ([]ssh.Signer, error) {
+	if a.agentConn != nil {
+		signers, err := agent.NewClient(a.agentConn).Signers()
+		if err == nil {
+			return signers, nil
+		}
+		a.agentConn.Close()
+		a.agentConn = nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, errors.New("SSH_AUTH_SOCK isn't set")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	a.agentConn = conn
+
+	return agent.NewClient(conn).Signers()
+}
+
+// describe lists the methods tried by the last connection
+func (a *sshAuth) describe() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.tried) == 0 {
+		return "no ssh key configured nor ssh-agent available"
+	}
+	return strings.Join(a.tried, ", ")
+}
+
+func loadSSHKey(key configuration.Ssh) (ssh.Signer, error) {
+	privateKey, err := misc.AbsolutePath(key.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.Passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(b, []byte(key.Passphrase))
+	}
+	return ssh.ParsePrivateKey(b)
+}
+
+// authError explains SSH authentication failures with the methods tried
+func (r *Repository) authError(err error) error {
+	auth, ok := r.auth.(*sshAuth)
+	if !ok || err == nil || !strings.Contains(err.Error(), "unable to authenticate") {
+		return err
+	}
+
+	return fmt.Errorf("%w, tried: %s: %v", ErrSSHAuth, auth.describe(), err)
+}
@@ -0,0 +1,200 @@
+package git
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testAgent is an ssh-agent serving a keyring on a unix socket
+type testAgent struct {
+	socket string
+	mu     sync.Mutex
+	conns  []net.Conn
+}
+
+// startAgent serves an agent holding a key and sets SSH_AUTH_SOCK to its socket
+func startAgent(t *testing.T) *testAgent {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &testAgent{socket: path.Join(t.TempDir(), "agent.sock")}
+	l, err := net.Listen("unix", a.socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	t.Setenv("SSH_AUTH_SOCK", a.socket)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			a.mu.Lock()
+			a.conns = append(a.conns, conn)
+			a.mu.Unlock()
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	return a
+}
+
+// connections returns the number of connections accepted by the agent
+func (a *testAgent) connections() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return len(a.conns)
+}
+
+// closeConnections breaks the connections opened to the agent
+func (a *testAgent) closeConnections() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, c := range a.conns {
+		c.Close()
+	}
+}
+
+func TestSigners(t *testing.T) {
+	keyFile := generateSSHKey(t, "ed25519", "")
+	a := startAgent(t)
+
+	auth := &sshAuth{
+		keys: []configuration.Ssh{
+			{PrivateKey: keyFile},
+			{PrivateKey: path.Join(t.TempDir(), "missing")},
+		},
+		agent: true,
+	}
+
+	signers, err := auth.signers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// * the missing key is skipped, the configured key comes before the agent key
+	if len(signers) != 2 {
+		t.Fatalf("signers() returned %d signers, want 2", len(signers))
+	}
+	tried := auth.describe()
+	for _, want := range []string{"key " + keyFile + " (SHA256:", "missing (", "ssh-agent (SHA256:"} {
+		if !strings.Contains(tried, want) {
+			t.Errorf("describe() = %q, want it to contain %q", tried, want)
+		}
+	}
+
+	t.Run("connection reused", func(t *testing.T) {
+		if _, err := auth.signers(); err != nil {
+			t.Fatal(err)
+		}
+		if n := a.connections(); n != 1 {
+			t.Errorf("agent accepted %d connections, want 1", n)
+		}
+	})
+
+	t.Run("broken connection reopened", func(t *testing.T) {
+		a.closeConnections()
+
+		signers, err := auth.signers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(signers) != 2 {
+			t.Errorf("signers() returned %d signers, want 2", len(signers))
+		}
+		if n := a.connections(); n != 2 {
+			t.Errorf("agent accepted %d connections, want 2", n)
+		}
+	})
+}
+
+func TestSignersWithoutAgent(t *testing.T) {
+	startAgent(t)
+
+	auth := &sshAuth{agent: false}
+	if signers, err := auth.signers(); err != nil || len(signers) != 0 {
+		t.Errorf("signers() = (%d signers, %v), want the agent to be ignored", len(signers), err)
+	}
+	if got := auth.describe(); got != "no ssh key configured nor ssh-agent available" {
+		t.Errorf("describe() = %q", got)
+	}
+
+	os.Unsetenv("SSH_AUTH_SOCK")
+	auth = &sshAuth{agent: true}
+	if _, err := auth.signers(); err != nil {
+		t.Fatal(err)
+	}
+	if got := auth.describe(); got != "ssh-agent (SSH_AUTH_SOCK isn't set)" {
+		t.Errorf("describe() = %q", got)
+	}
+}
+
+func TestSSHOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  interface{}
+		want    []configuration.Ssh
+		wantErr bool
+	}{
+		{name: "not configured", config: nil},
+		{
+			name:   "single key",
+			config: map[string]interface{}{"private-key": "~/.ssh/id_ed25519", "passphrase": "secret"},
+			want:   []configuration.Ssh{{PrivateKey: "~/.ssh/id_ed25519", Passphrase: "secret"}},
+		},
+		{
+			name:   "default configuration",
+			config: map[string]interface{}{"private-key": "", "passphrase": ""},
+			want:   []configuration.Ssh{},
+		},
+		{
+			name: "list of keys",
+			config: []interface{}{
+				map[string]interface{}{"private-key": "~/.ssh/id_ed25519"},
+				map[interface{}]interface{}{"private-key": "~/.ssh/id_rsa"},
+			},
+			want: []configuration.Ssh{{PrivateKey: "~/.ssh/id_ed25519"}, {PrivateKey: "~/.ssh/id_rsa"}},
+		},
+		{
+			name: "single key before the list",
+			config: map[string]interface{}{
+				"private-key": "~/.ssh/id_ed25519",
+				"keys":        []interface{}{map[string]interface{}{"private-key": "~/.ssh/id_rsa"}},
+			},
+			want: []configuration.Ssh{{PrivateKey: "~/.ssh/id_ed25519"}, {PrivateKey: "~/.ssh/id_rsa"}},
+		},
+		{name: "passphrase without key", config: []interface{}{map[string]interface{}{"passphrase": "secret"}}, wantErr: true},
+		{name: "invalid type", config: "~/.ssh/id_ed25519", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := sshOptions(tc.config)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("sshOptions() error = %v, want error: %t", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got.Keys, tc.want) {
+				t.Errorf("sshOptions() keys = %+v, want %+v", got.Keys, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var (
+	ErrDirtyWorktree  = errors.New("repository has uncommitted changes, save them with \"save --push\" first")
+	ErrBranchNotFound = errors.New("branch not found locally nor on the remote")
+	ErrInvalidItem    = errors.New("item must be a path inside the repository")
+)
+
+// BranchData is given to the branch name template
+type BranchData struct {
+	Hostname string
+	OS       string
+}
+
+// ResolveBranch executes a branch name template (E.g: "hosts/{{.Hostname}}")
+func ResolveBranch(tmpl string) (string, error) {
+	if !strings.Contains(tmpl, "{{") {
+		return tmpl, nil
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+
+	t, err := template.New("branch").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid branch template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, BranchData{Hostname: host, OS: runtime.GOOS}); err != nil {
+		return "", fmt.Errorf("failed to generate branch name: %v", err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Branch returns the checked out branch
+func (r *Repository) Branch() string {
+	head, err := r.repository.Head()
+	if err != nil {
+		return ""
+	}
+
+	return head.Name().Short()
+}
+
+// checkoutBranch checks out a branch. It's created from the remote branch if any,
+// or from the current commit otherwise (it'll be created on the remote by the next push).
+func (r *Repository) checkoutBranch(repo *git.Repository, name string) error {
+	head, err := repo.Head()
+	if err != nil {
+		// * nothing was committed yet, the branch is created with the first commit
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(name)))
+		}
+		return err
+	}
+	if head.Name() == plumbing.NewBranchReferenceName(name) {
+		return nil
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	opts := &git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(name)}
+	if _, err := repo.Reference(opts.Branch, false); err != nil {
+		if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return err
+		}
+
+		opts.Create = true
+		opts.Hash = head.Hash()
+		if remote, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", name), true); err == nil {
+			opts.Hash = remote.Hash()
+		}
+	}
+
+	if err := w.Checkout(opts); err != nil {
+		if errors.Is(err, git.ErrUnstagedChanges) {
+			return fmt.Errorf("failed to checkout branch %s: %w", name, ErrDirtyWorktree)
+		}
+		return fmt.Errorf("failed to checkout branch %s: %v", name, err)
+	}
+
+	return nil
+}
+
+// push pushes a local branch to the remote branch with the same name
+func (r *Repository) push(repo *git.Repository, branch string) error {
+	ref := plumbing.NewBranchReferenceName(branch)
+	err := repo.Push(&git.PushOptions{
+		Auth:     r.auth,
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", ref, ref))},
+	})
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+
+	return err
+}
+
+// Promote copies items (paths relative to the repository) from a branch into another one and pushes the result.
+//
+// Items are copied as they are in the source branch: their files are replaced and removed from the target branch.
+// They aren't merged nor cherry-picked, commits of a host branch touch every item of the host at once
+// and branches of different hosts don't share a merge base for their files.
+// If the promotion fails, the target branch is reset to its last commit.
+// The current branch is checked out back afterwards, failing to do so is reported unless the promotion failed.
+func (r *Repository) Promote(from, to string, items []string) (err error) {
+	items, err = promoteItems(items)
+	if err != nil {
+		return err
+	}
+
+	w, err := r.repository.Worktree()
+	if err != nil {
+		return err
+	}
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+	if !status.IsClean() {
+		return ErrDirtyWorktree
+	}
+
+	source, err := r.branchCommit(from)
+	if err != nil {
+		return err
+	}
+	tree, err := source.Tree()
+	if err != nil {
+		return err
+	}
+
+	// * the current branch is checked out back afterwards, a detached HEAD couldn't be
+	head, err := r.repository.Head()
+	if err != nil {
+		return err
+	}
+	if !head.Name().IsBranch() {
+		return ErrDetachedHead
+	}
+	current := head.Name().Short()
+
+	if err := r.checkoutBranch(r.repository, to); err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		failed := err != nil && !errors.Is(err, NoErrQueued) && !errors.Is(err, NoErrNothingToCommit)
+		if failed && !committed {
+			if resetErr := resetWorktree(r.repository, w); resetErr != nil {
+				err = fmt.Errorf("%w (failed to reset %s: %v)", err, to, resetErr)
+			}
+		}
+
+		checkoutErr := r.checkoutBranch(r.repository, current)
+		if checkoutErr == nil {
+			return
+		}
+		if failed {
+			err = fmt.Errorf("%w (failed to check out %s back: %v)", err, current, checkoutErr)
+		} else {
+			err = fmt.Errorf("failed to check out %s back: %w", current, checkoutErr)
+		}
+	}()
+
+	if !r.offline {
+		if err := r.pull(r.repository); err != nil && !isUnreachable(err) {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		if err := os.RemoveAll(path.Join(r.repoPath, item)); err != nil {
+			return err
+		}
+		if err := writeTreeEntry(tree, item, path.Join(r.repoPath, item)); err != nil {
+			return fmt.Errorf("failed to promote %s: %v", item, err)
+		}
+	}
+
+	status, err = w.Status()
+	if err != nil {
+		return err
+	}
+	if status.IsClean() {
+		return NoErrNothingToCommit
+	}
+	for file := range status {
+		if _, err := w.Add(file); err != nil {
+			return err
+		}
+	}
+
+	msg := fmt.Sprintf("promote(%s): %s", from, strings.Join(items, ", "))
+	if _, err := r.commit(w, msg); err != nil {
+		return err
+	}
+	committed = true
+
+	if r.offline {
+		return NoErrQueued
+	}
+	if err := r.push(r.repository, to); err != nil {
+		if isUnreachable(err) {
+			return NoErrQueued
+		}
+		return r.authError(err)
+	}
+
+	return nil
+}
+
+// promoteItems cleans items and rejects the ones which are empty, absolute or outside the repository
+func promoteItems(items []string) ([]string, error) {
+	cleaned := make([]string, 0, len(items))
+	for _, item := range items {
+		p := path.Clean(filepath.ToSlash(item))
+		if item == "" || path.IsAbs(p) || filepath.IsAbs(item) || p == "." || p == ".." || strings.HasPrefix(p, "../") ||
+			p == git.GitDirName || strings.HasPrefix(p, git.GitDirName+"/") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidItem, item)
+		}
+		cleaned = append(cleaned, p)
+	}
+
+	return cleaned, nil
+}
+
+// resetWorktree discards the changes of the worktree and removes its untracked files
+func resetWorktree(repo *git.Repository, w *git.Worktree) error {
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	if err := w.Reset(&git.ResetOptions{Mode: git.HardReset, Commit: head.Hash()}); err != nil {
+		return err
+	}
+
+	return w.Clean(&git.CleanOptions{Dir: true})
+}
+
+// branchCommit returns the last commit of a local branch, or of the remote branch if it doesn't exist locally
+func (r *Repository) branchCommit(name string) (*object.Commit, error) {
+	ref, err := r.repository.Reference(plumbing.NewBranchReferenceName(name), true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		ref, err = r.repository.Reference(plumbing.NewRemoteReferenceName("origin", name), true)
+	}
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrBranchNotFound, name)
+		}
+		return nil, err
+	}
+
+	return r.repository.CommitObject(ref.Hash())
+}
+
+// writeTreeEntry writes a file or a folder of a commit tree onto the disk. Missing entries are ignored.
+func writeTreeEntry(tree *object.Tree, p, dst string) error {
+	entry, err := tree.FindEntry(p)
+	if err != nil {
+		if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if entry.Mode.IsFile() {
+		f, err := tree.TreeEntryFile(entry)
+		if err != nil {
+			return err
+		}
+		return writeTreeFile(f, dst)
+	}
+
+	sub, err := tree.Tree(p)
+	if err != nil {
+		return err
+	}
+
+	return sub.Files().ForEach(func(f *object.File) error {
+		return writeTreeFile(f, path.Join(dst, f.Name))
+	})
+}
+
+func writeTreeFile(f *object.File, dst string) error {
+	perms, err := f.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perms)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, r)
+	return err
+}
@@ -0,0 +1,233 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path"
+	"reflect"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestResolveBranch(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain branch", tmpl: "main", want: "main"},
+		{name: "hostname", tmpl: "hosts/{{.Hostname}}", want: "hosts/" + host},
+		{name: "os", tmpl: "{{.OS}}/{{.Hostname}}", want: runtime.GOOS + "/" + host},
+		{name: "trimmed", tmpl: " {{.OS}} ", want: runtime.GOOS},
+		{name: "invalid template", tmpl: "hosts/{{.Hostname", wantErr: true},
+		{name: "unknown field", tmpl: "{{.User}}", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ResolveBranch(tc.tmpl)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveBranch(%q) = %q, want %q", tc.tmpl, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPromoteItems(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		items   []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "cleaned paths", items: []string{".zshrc", "nvim/", "./a//b"}, want: []string{".zshrc", "nvim", "a/b"}},
+		{name: "dot dot inside the repository", items: []string{"a/../b"}, want: []string{"b"}},
+		{name: "empty", items: []string{""}, wantErr: true},
+		{name: "repository root", items: []string{"."}, wantErr: true},
+		{name: "root after cleaning", items: []string{"a/.."}, wantErr: true},
+		{name: "absolute", items: []string{"/etc/hosts"}, wantErr: true},
+		{name: "outside the repository", items: []string{"../x"}, wantErr: true},
+		{name: "git directory", items: []string{".git/config"}, wantErr: true},
+		{name: "one invalid item", items: []string{".zshrc", ".."}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := promoteItems(tc.items)
+			if tc.wantErr {
+				if !errors.Is(err, ErrInvalidItem) {
+					t.Errorf("promoteItems(%q) = %v, want %v", tc.items, err, ErrInvalidItem)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("promoteItems(%q) = %q, want %q", tc.items, got, tc.want)
+			}
+		})
+	}
+}
+
+// newPromoteRepository creates a repository with a "main" branch and a "hosts/laptop" branch checked out.
+// Files are committed in alphabetical order.
+func newPromoteRepository(t *testing.T, main, host map[string]string) *Repository {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))); err != nil {
+		t.Fatal(err)
+	}
+	r := &Repository{repository: repo, repoPath: dir, offline: true, author: author{name: "a", email: "a@a"}}
+
+	commitFiles := func(files map[string]string) {
+		w, err := repo.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			content := files[name]
+			p := path.Join(dir, name)
+			// * an empty content removes the file
+			if content == "" {
+				if _, err := w.Remove(name); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Add(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := r.commit(w, "commit"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	commitFiles(main)
+	if err := r.checkoutBranch(repo, "hosts/laptop"); err != nil {
+		t.Fatal(err)
+	}
+	commitFiles(host)
+
+	return r
+}
+
+func TestPromote(t *testing.T) {
+	r := newPromoteRepository(t,
+		map[string]string{".zshrc": "main", "nvim/old.lua": "old"},
+		map[string]string{".zshrc": "laptop", "nvim/init.lua": "init", "nvim/old.lua": ""},
+	)
+
+	if err := r.Promote("hosts/laptop", "main", []string{".zshrc", "nvim/"}); !errors.Is(err, NoErrQueued) {
+		t.Fatalf("Promote() = %v, want %v", err, NoErrQueued)
+	}
+
+	head, err := r.repository.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name().Short() != "hosts/laptop" {
+		t.Errorf("current branch = %s, want hosts/laptop", head.Name().Short())
+	}
+
+	main, err := r.branchCommit("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main.Message != "promote(hosts/laptop): .zshrc, nvim" {
+		t.Errorf("promote commit message = %q", main.Message)
+	}
+	tree, err := main.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{".zshrc": "laptop", "nvim/init.lua": "init"} {
+		f, err := tree.File(name)
+		if err != nil {
+			t.Fatalf("%s isn't in main: %v", name, err)
+		}
+		if got, _ := f.Contents(); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if _, err := tree.File("nvim/old.lua"); err == nil {
+		t.Error("nvim/old.lua removed from the host branch is still in main")
+	}
+}
+
+func TestPromoteInvalidItems(t *testing.T) {
+	r := newPromoteRepository(t, map[string]string{".zshrc": "main"}, map[string]string{".zshrc": "laptop"})
+
+	for _, item := range []string{".", "../outside", "/tmp"} {
+		if err := r.Promote("hosts/laptop", "main", []string{item}); !errors.Is(err, ErrInvalidItem) {
+			t.Errorf("Promote(%q) = %v, want %v", item, err, ErrInvalidItem)
+		}
+	}
+	if _, err := os.Stat(path.Join(r.repoPath, ".zshrc")); err != nil {
+		t.Errorf("repository files were changed: %v", err)
+	}
+}
+
+func TestPromoteFailureResetsTarget(t *testing.T) {
+	// * "config" is a file in main, writing "config/app.toml" fails after ".zshrc" was written
+	r := newPromoteRepository(t,
+		map[string]string{".zshrc": "main", "config": "file"},
+		map[string]string{".zshrc": "laptop", "new": "untracked in main", "config": "", "config/app.toml": "app"},
+	)
+	w, err := r.repository.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mainHead, err := r.branchCommit("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Promote("hosts/laptop", "main", []string{".zshrc", "new", "config/app.toml"}); err == nil {
+		t.Fatal("Promote() succeeded, want an error")
+	}
+
+	head, err := r.repository.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Name().Short() != "hosts/laptop" {
+		t.Errorf("current branch = %s, want hosts/laptop", head.Name().Short())
+	}
+	status, err := w.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.IsClean() {
+		t.Errorf("worktree isn't clean after the failed promotion:\n%s", status)
+	}
+	if main, err := r.branchCommit("main"); err != nil || main.Hash != mainHead.Hash {
+		t.Errorf("main moved to %v (%v), want %s", main.Hash, err, mainHead.Hash)
+	}
+}
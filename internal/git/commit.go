@@ -0,0 +1,138 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// DefaultCommitTemplate generates messages like "save(host): updated .zshrc, nvim/"
+const DefaultCommitTemplate = "save({{.Host}}): {{.Summary}}"
+
+// NoErrNothingToCommit is returned by PushChanges when the worktree is clean
+var NoErrNothingToCommit = errors.New("nothing to commit")
+
+// CommitData is given to the commit message template
+type CommitData struct {
+	Host string
+	// Added, Updated and Removed hold the changed items relative to the saved location.
+	// Folders end with a "/".
+	Added   []string
+	Updated []string
+	Removed []string
+	// Items holds all changed items
+	Items []string
+	// Summary lists changed items by kind (E.g: "added .vimrc; updated .zshrc, nvim/")
+	Summary string
+}
+
+// newCommitData maps the worktree status to the saved items listed in the index
+func newCommitData(status git.Status, lines []string) CommitData {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	type itemStatus struct {
+		folder    bool
+		untracked int
+		deleted   int
+		total     int
+	}
+	items := map[string]*itemStatus{}
+	for file, s := range status {
+		// * the index changes along with the items
+		if file == ".index" {
+			continue
+		}
+
+		name, folder := file, false
+		for _, l := range lines {
+			if l != "" && strings.HasPrefix(file, l+"/") {
+				name, folder = l, true
+				break
+			}
+		}
+
+		item, ok := items[name]
+		if !ok {
+			item = &itemStatus{folder: folder}
+			items[name] = item
+		}
+		item.total++
+		if s.Worktree == git.Untracked || s.Staging == git.Added {
+			item.untracked++
+		}
+		if s.Worktree == git.Deleted || s.Staging == git.Deleted {
+			item.deleted++
+		}
+	}
+
+	data := CommitData{
+		Host:    host,
+		Added:   []string{},
+		Updated: []string{},
+		Removed: []string{},
+		Items:   []string{},
+	}
+	for name, item := range items {
+		if item.folder {
+			name += "/"
+		}
+
+		switch item.total {
+		case item.untracked:
+			data.Added = append(data.Added, name)
+		case item.deleted:
+			data.Removed = append(data.Removed, name)
+		default:
+			data.Updated = append(data.Updated, name)
+		}
+		data.Items = append(data.Items, name)
+	}
+	sort.Strings(data.Added)
+	sort.Strings(data.Updated)
+	sort.Strings(data.Removed)
+	sort.Strings(data.Items)
+
+	summary := []string{}
+	for _, kind := range []struct {
+		verb  string
+		items []string
+	}{{"added", data.Added}, {"updated", data.Updated}, {"removed", data.Removed}} {
+		if len(kind.items) > 0 {
+			summary = append(summary, fmt.Sprintf("%s %s", kind.verb, strings.Join(kind.items, ", ")))
+		}
+	}
+	data.Summary = strings.Join(summary, "; ")
+	if data.Summary == "" {
+		data.Summary = "updated index"
+	}
+
+	return data
+}
+
+// commitMessage executes the commit message template. The "join" function is available (E.g: {{join .Items ", "}}).
+func commitMessage(tmpl string, data CommitData) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultCommitTemplate
+	}
+
+	t, err := template.New("commit").Funcs(template.FuncMap{"join": strings.Join}).Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid commit template: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to generate commit message: %v", err)
+	}
+
+	return strings.TrimSpace(buf.String()), nil
+}
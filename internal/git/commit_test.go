@@ -0,0 +1,72 @@
+package git
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestCommitMessage(t *testing.T) {
+	data := CommitData{
+		Host:    "laptop",
+		Added:   []string{".vimrc"},
+		Updated: []string{".zshrc", "nvim/"},
+		Removed: []string{},
+		Items:   []string{".vimrc", ".zshrc", "nvim/"},
+		Summary: "added .vimrc; updated .zshrc, nvim/",
+	}
+
+	for _, tc := range []struct {
+		name    string
+		tmpl    string
+		want    string
+		wantErr bool
+	}{
+		{name: "default template", tmpl: "", want: "save(laptop): added .vimrc; updated .zshrc, nvim/"},
+		{name: "join function", tmpl: "sync {{join .Items \" \"}}", want: "sync .vimrc .zshrc nvim/"},
+		{name: "trimmed", tmpl: "  {{.Host}}\n\n", want: "laptop"},
+		{name: "invalid template", tmpl: "{{.Host", wantErr: true},
+		{name: "unknown field", tmpl: "{{.Unknown}}", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := commitMessage(tc.tmpl, data)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("err = %v, want error: %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("commitMessage(%q) = %q, want %q", tc.tmpl, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewCommitData(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := git.Status{
+		".index":           &git.FileStatus{Worktree: git.Modified},
+		".vimrc":           &git.FileStatus{Worktree: git.Untracked},
+		".zshrc":           &git.FileStatus{Worktree: git.Modified},
+		".bashrc":          &git.FileStatus{Worktree: git.Deleted},
+		"nvim/init.lua":    &git.FileStatus{Worktree: git.Modified},
+		"nvim/lua/new.lua": &git.FileStatus{Worktree: git.Untracked},
+		"kitty/kitty.conf": &git.FileStatus{Staging: git.Added},
+	}
+
+	want := CommitData{
+		Host:    host,
+		Added:   []string{".vimrc", "kitty/"},
+		Updated: []string{".zshrc", "nvim/"},
+		Removed: []string{".bashrc"},
+		Items:   []string{".bashrc", ".vimrc", ".zshrc", "kitty/", "nvim/"},
+		Summary: "added .vimrc, kitty/; updated .zshrc, nvim/; removed .bashrc",
+	}
+	if got := newCommitData(status, []string{"nvim", "kitty", ".vimrc"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("newCommitData() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,262 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+var (
+	ErrPasswordSources = errors.New("only one of password, password-env and password-command can be set")
+	ErrPasswordEnv     = errors.New("password environment variable is empty or not set")
+	ErrPasswordCommand = errors.New("password command failed")
+)
+
+// credentials is a username and password resolved from a credential source
+type credentials struct {
+	username string
+	password string
+}
+
+// basicAuth resolves the HTTPS credentials. A configured password source is required to succeed,
+// otherwise the netrc file and the git credential helpers are looked up and no authentication is used if they have none.
+//
+// Passwords must never be logged nor included in errors.
+func basicAuth(config configuration.BasicAuth, endpoint *transport.Endpoint) (transport.AuthMethod, error) {
+	creds, err := configuredCredentials(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if creds == nil {
+		if creds, err = netrcCredentials(config.Netrc, endpoint.Host, config.Username); err != nil {
+			return nil, err
+		}
+	}
+	if creds == nil && !config.DisableCredentialHelper {
+		creds = helperCredentials(endpoint, config.Username)
+	}
+
+	if creds == nil {
+		if config.Username == "" && endpoint.User == "" {
+			return nil, nil
+		}
+		creds = &credentials{}
+	}
+
+	// * the configured username wins over the one of the credential source, then the one of the URL
+	username := config.Username
+	if username == "" {
+		username = creds.username
+	}
+	if username == "" {
+		username = endpoint.User
+	}
+
+	return &http.BasicAuth{Username: username, Password: creds.password}, nil
+}
+
+// configuredCredentials returns the password set in the configuration, or nil if none is set
+func configuredCredentials(config configuration.BasicAuth) (*credentials, error) {
+	sources := 0
+	for _, s := range []string{config.Password, config.PasswordEnv, config.PasswordCommand} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, ErrPasswordSources
+	}
+
+	switch {
+	case config.Password != "":
+		return &credentials{password: config.Password}, nil
+	case config.PasswordEnv != "":
+		password := os.Getenv(config.PasswordEnv)
+		if password == "" {
+			return nil, fmt.Errorf("%w: %s", ErrPasswordEnv, config.PasswordEnv)
+		}
+		return &credentials{password: password}, nil
+	case config.PasswordCommand != "":
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", config.PasswordCommand)
+		cmd.Stdin = os.Stdin
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			// * only stderr is reported, stdout could hold part of the password
+			return nil, fmt.Errorf("%w: %v: %s", ErrPasswordCommand, err, strings.TrimSpace(stderr.String()))
+		}
+
+		password := strings.SplitN(string(out), "\n", 2)[0]
+		if password == "" {
+			return nil, fmt.Errorf("%w: nothing printed on the first line", ErrPasswordCommand)
+		}
+		return &credentials{password: password}, nil
+	}
+
+	return nil, nil
+}
+
+// netrcCredentials looks up the credentials of a host in a netrc file, falling back to its "default" entry.
+// The entry must match the username if one is given.
+func netrcCredentials(file, host, username string) (*credentials, error) {
+	if file == "" {
+		file = os.Getenv("NETRC")
+	}
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, nil
+		}
+		file = path.Join(home, ".netrc")
+	}
+
+	file, err := misc.AbsolutePath(file)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	type netrcEntry struct {
+		credentials
+		machine   string
+		isDefault bool
+	}
+
+	entries := []netrcEntry{}
+	tokens := netrcTokens(b)
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] == "default" {
+			entries = append(entries, netrcEntry{isDefault: true})
+			continue
+		}
+		if i+1 >= len(tokens) {
+			break
+		}
+
+		key, value := tokens[i], tokens[i+1]
+		i++
+		switch {
+		case key == "machine":
+			entries = append(entries, netrcEntry{machine: value})
+		case key == "login" && len(entries) > 0:
+			entries[len(entries)-1].username = value
+		case key == "password" && len(entries) > 0:
+			entries[len(entries)-1].password = value
+		}
+	}
+
+	var fallback *credentials
+	for _, e := range entries {
+		if username != "" && e.username != username {
+			continue
+		}
+		creds := e.credentials
+		if e.machine == host {
+			return &creds, nil
+		}
+		if e.isDefault && fallback == nil {
+			fallback = &creds
+		}
+	}
+
+	return fallback, nil
+}
+
+// netrcTokens splits a netrc file into tokens, skipping comments and macro definitions
+func netrcTokens(b []byte) []string {
+	tokens := []string{}
+	inMacro := false
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if inMacro {
+			inMacro = line != ""
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i, f := range fields {
+			if strings.HasPrefix(f, "#") {
+				break
+			}
+			tokens = append(tokens, f)
+			if f == "macdef" {
+				// * keep the macro name, its body starts on the next line
+				if i+1 < len(fields) {
+					tokens = append(tokens, fields[i+1])
+				}
+				inMacro = true
+				break
+			}
+		}
+	}
+
+	return tokens
+}
+
+// helperCredentials asks the git credential helpers without prompting, nil is returned if they have nothing
+func helperCredentials(endpoint *transport.Endpoint, username string) *credentials {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil
+	}
+
+	input := fmt.Sprintf("protocol=%s\nhost=%s\n", endpoint.Protocol, endpoint.Host)
+	if endpoint.Port != 0 {
+		input = fmt.Sprintf("protocol=%s\nhost=%s:%d\n", endpoint.Protocol, endpoint.Host, endpoint.Port)
+	}
+	if p := strings.TrimPrefix(endpoint.Path, "/"); p != "" {
+		input += fmt.Sprintf("path=%s\n", p)
+	}
+	if username != "" {
+		input += fmt.Sprintf("username=%s\n", username)
+	}
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(input + "\n")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	creds := &credentials{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		kv := strings.SplitN(s.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "username":
+			creds.username = kv[1]
+		case "password":
+			creds.password = kv[1]
+		}
+	}
+	if creds.password == "" {
+		return nil
+	}
+
+	return creds
+}
@@ -0,0 +1,92 @@
+package git
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestNetrcTokens(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		netrc string
+		want  []string
+	}{
+		{
+			name:  "single line",
+			netrc: "machine host login user password secret",
+			want:  []string{"machine", "host", "login", "user", "password", "secret"},
+		},
+		{
+			name:  "multiple lines",
+			netrc: "machine host\n  login user\n  password secret\ndefault login anonymous",
+			want:  []string{"machine", "host", "login", "user", "password", "secret", "default", "login", "anonymous"},
+		},
+		{
+			name:  "comments",
+			netrc: "# comment\nmachine host login user # trailing comment\n",
+			want:  []string{"machine", "host", "login", "user"},
+		},
+		{
+			name:  "macro definitions",
+			netrc: "macdef init\ncd /pub\nbin\n\nmachine host login user",
+			want:  []string{"macdef", "init", "machine", "host", "login", "user"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := netrcTokens([]byte(tc.netrc)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("netrcTokens() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNetrcCredentials(t *testing.T) {
+	netrc := `machine github.com
+  login alice
+  password alice-token
+machine github.com login bob password bob-token
+macdef upload
+machine fake.com login mallory password macro-body
+
+machine gitlab.com login carol password carol-token
+default login anonymous password anonymous-token
+`
+
+	for _, tc := range []struct {
+		name     string
+		host     string
+		username string
+		want     *credentials
+	}{
+		{"first entry of the host", "github.com", "", &credentials{username: "alice", password: "alice-token"}},
+		{"entry matching the username", "github.com", "bob", &credentials{username: "bob", password: "bob-token"}},
+		{"other host", "gitlab.com", "", &credentials{username: "carol", password: "carol-token"}},
+		{"default entry", "codeberg.org", "", &credentials{username: "anonymous", password: "anonymous-token"}},
+		{"no entry for the username", "github.com", "dave", nil},
+		{"macro bodies are skipped", "fake.com", "mallory", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file := path.Join(t.TempDir(), ".netrc")
+			if err := os.WriteFile(file, []byte(netrc), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := netrcCredentials(file, tc.host, tc.username)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("netrcCredentials(%s, %q) = %+v, want %+v", tc.host, tc.username, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNetrcCredentialsMissingFile(t *testing.T) {
+	got, err := netrcCredentials(path.Join(t.TempDir(), ".netrc"), "github.com", "")
+	if err != nil || got != nil {
+		t.Errorf("netrcCredentials() = (%+v, %v), want no credentials nor error", got, err)
+	}
+}
@@ -11,9 +11,6 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
-	"github.com/mitchellh/mapstructure"
 )
 
 var (
@@ -24,15 +21,30 @@ type RepositoryActions interface {
 	PushChanges(msg string, newLines, removedLines []string) error
 	GetWorktree() (*git.Worktree, error)
 	GetAuthor() *object.Signature
+	Offline() bool
+	Status() (RepositoryStatus, error)
+	Branch() string
+	Promote(from, to string, items []string) error
+	CreateSnapshot(name, description string) error
+	Snapshots() ([]Snapshot, error)
+	ExtractRef(ref, dst string) error
+	Log(p string, patch bool) ([]LogEntry, error)
 	openRepository() error
 }
 
 type Repository struct {
-	auth       transport.AuthMethod
-	repository *git.Repository
-	repoPath   string
-	author     author
-	url        string
+	auth           transport.AuthMethod
+	repository     *git.Repository
+	repoPath       string
+	author         author
+	url            string
+	commitTemplate string
+	pullStrategy   string
+	// branch is checked out when the repository is opened, the current branch is kept if empty
+	branch string
+	// offline is set when the remote must not or can't be contacted
+	offline bool
+	signer  *signer
 }
 
 type author struct {
@@ -40,8 +52,11 @@ type author struct {
 	email string
 }
 
-func NewRepository(config configuration.Git, repoPath string) (RepositoryActions, error) {
-	var auth transport.AuthMethod
+// NewRepository opens the storage repository, cloning it if needed, and pulls remote changes.
+//
+// In offline mode, or if the remote is unreachable, the local clone is used as is.
+// Otherwise, commits queued by previous offline runs are pushed.
+func NewRepository(config configuration.Git, repoPath string, offline bool) (RepositoryActions, error) {
 	if config.URL == "" {
 		return nil, errors.New("a repository URI is needed (either using GIT protocol or HTTPS)")
 	}
@@ -50,45 +65,9 @@ func NewRepository(config configuration.Git, repoPath string) (RepositoryActions
 		return nil, err
 	}
 
-	switch sshConfig := config.SSH.(type) {
-	case map[string]interface{}:
-		var outConfig configuration.Ssh
-		if err := mapstructure.Decode(sshConfig, &outConfig); err != nil {
-			return nil, err
-		}
-
-		auth, err = getSSHAuthMethod(outConfig)
-		if err != nil {
-			return nil, err
-		}
-	case []interface{}:
-		for i, c := range sshConfig {
-			if _, ok := c.(map[interface{}]interface{}); !ok {
-				fmt.Printf("invalid format for configuration n°%d", i)
-				continue
-			}
-
-			var outConfig configuration.Ssh
-			if err := mapstructure.Decode(c, &outConfig); err != nil {
-				fmt.Printf("failed to decode ssh configuration n°%d: %v\n", i, err)
-				continue
-			}
-
-			auth, err = getSSHAuthMethod(outConfig)
-			if err != nil {
-				fmt.Printf("failed to create SSH authentication method for configuration n°%d: %v\n", i, err)
-				continue
-			}
-		}
-
-		if auth == nil {
-			auth = &http.BasicAuth{
-				Username: config.BasicAuth.Username,
-				Password: config.BasicAuth.Password,
-			}
-		}
-	default:
-		return nil, errors.New("git ssh configuration canno't be unmarshaled. Please, pass a valid configuration")
+	auth, err := newAuthMethod(config)
+	if err != nil {
+		return nil, err
 	}
 
 	repo := &Repository{
@@ -100,6 +79,22 @@ func NewRepository(config configuration.Git, repoPath string) (RepositoryActions
 			name:  config.Name,
 			email: config.Email,
 		},
+		commitTemplate: config.CommitTemplate,
+		pullStrategy:   config.PullStrategy,
+		offline:        offline,
+	}
+
+	if repo.branch, err = ResolveBranch(config.Branch); err != nil {
+		return nil, err
+	}
+	if repo.signer, err = newSigner(config.Signing); err != nil {
+		return nil, err
+	}
+	// * commits created by merging or rebasing are signed by git, which can't use every key
+	if config.PullStrategy == PullMerge || config.PullStrategy == PullRebase {
+		if _, err := repo.signer.gitArgs(); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := repo.openRepository(); err != nil {
@@ -113,16 +108,23 @@ func (r *Repository) openRepository() error {
 	s, err := os.Stat(r.repoPath)
 	if err != nil {
 		if os.IsNotExist(err) {
+			if r.offline {
+				return ErrOfflineNoClone
+			}
+
 			repo, err := git.PlainClone(r.repoPath, false, &git.CloneOptions{
 				URL:      r.url,
 				Progress: os.Stdout,
 				Auth:     r.auth,
 			})
 			if err != nil {
-				return err
+				return r.authError(err)
 			}
 
 			r.repository = repo
+			if r.branch != "" {
+				return r.checkoutBranch(repo, r.branch)
+			}
 			return nil
 		}
 
@@ -137,23 +139,42 @@ func (r *Repository) openRepository() error {
 	if err != nil {
 		return err
 	}
+	r.repository = repo
 
-	w, err := repo.Worktree()
-	if err != nil {
-		return err
+	if r.branch != "" {
+		if err := r.checkoutBranch(repo, r.branch); err != nil {
+			return err
+		}
 	}
 
-	err = w.Pull(&git.PullOptions{
-		Auth: r.auth,
-	})
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return err
+	if r.offline {
+		return nil
+	}
+
+	if err := r.pull(repo); err != nil {
+		if isUnreachable(err) {
+			r.offline = true
+			return nil
+		}
+		return r.authError(err)
+	}
+
+	if err := r.pushQueued(repo); err != nil {
+		if isUnreachable(err) {
+			r.offline = true
+			return nil
+		}
+		return fmt.Errorf("failed to push queued commits: %w", r.authError(err))
 	}
 
-	r.repository = repo
 	return nil
 }
 
+// PushChanges commits all changes of the worktree and pushes them.
+//
+// If msg is empty, the commit message is generated from the commit template with the changed items.
+// NoErrNothingToCommit is returned if the worktree is clean and NoErrQueued if the commit
+// couldn't be pushed because of the offline mode.
 func (r *Repository) PushChanges(msg string, newLines, removedLines []string) error {
 	w, err := r.repository.Worktree()
 	if err != nil {
@@ -164,6 +185,16 @@ func (r *Repository) PushChanges(msg string, newLines, removedLines []string) er
 	if err != nil {
 		return err
 	}
+	if status.IsClean() {
+		return NoErrNothingToCommit
+	}
+
+	if msg == "" {
+		msg, err = commitMessage(r.commitTemplate, newCommitData(status, append(append([]string{}, newLines...), removedLines...)))
+		if err != nil {
+			return err
+		}
+	}
 
 	for file := range status {
 		_, err = w.Add(file)
@@ -172,13 +203,21 @@ func (r *Repository) PushChanges(msg string, newLines, removedLines []string) er
 		}
 	}
 
-	if _, err := w.Commit(msg, &git.CommitOptions{
-		Author: r.GetAuthor(),
-	}); err != nil {
+	if _, err := r.commit(w, msg); err != nil {
 		return err
 	}
 
-	return r.repository.Push(&git.PushOptions{})
+	if r.offline {
+		return NoErrQueued
+	}
+
+	err = r.push(r.repository, r.Branch())
+	if isUnreachable(err) {
+		r.offline = true
+		return NoErrQueued
+	}
+
+	return r.authError(err)
 }
 
 func (r *Repository) GetWorktree() (*git.Worktree, error) {
@@ -192,25 +231,3 @@ func (r *Repository) GetAuthor() *object.Signature {
 		When:  time.Now(),
 	}
 }
-
-func getSSHAuthMethod(config configuration.Ssh) (transport.AuthMethod, error) {
-	if config.Passphrase == "" && config.PrivateKey == "" {
-		return nil, errors.New("passphrase and private are empty")
-	}
-
-	privateKey, err := misc.AbsolutePath(config.PrivateKey)
-	if err != nil {
-		return nil, err
-	}
-
-	if _, err := os.Stat(privateKey); err != nil {
-		return nil, err
-	}
-
-	auth, err := ssh.NewPublicKeysFromFile("git", privateKey, config.Passphrase)
-	if err != nil {
-		return nil, err
-	}
-
-	return auth, nil
-}
@@ -0,0 +1,256 @@
+package git
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	HostKeyStrict      = "strict"
+	HostKeyAcceptNew   = "accept-new"
+	HostKeyFingerprint = "fingerprint"
+)
+
+var (
+	ErrHostKeyPolicy       = errors.New("host key policy must be one of strict, accept-new or fingerprint")
+	ErrNoFingerprints      = errors.New("the fingerprint host key policy needs at least one fingerprint")
+	ErrUnknownHost         = errors.New("host key is unknown, trust it with \"config-mapper init --trust-host\"")
+	ErrHostKeyMismatch     = errors.New("host key doesn't match the known hosts file, the host changed its key or the connection is intercepted")
+	ErrFingerprintMismatch = errors.New("host key fingerprint isn't one of the configured fingerprints")
+	ErrNotSSH              = errors.New("repository URL doesn't use SSH")
+)
+
+// HostKey is the key presented by the SSH server of the repository
+type HostKey struct {
+	Host        string
+	Type        string
+	Fingerprint string
+	// Known is set when the key is already in the known hosts file
+	Known bool
+	key   ssh.PublicKey
+}
+
+// newHostKeyCallback verifies host keys with the configured policy
+func newHostKeyCallback(options configuration.SshOptions) (ssh.HostKeyCallback, error) {
+	policy := options.HostKeyPolicy
+	if policy == "" {
+		policy = HostKeyStrict
+	}
+
+	switch policy {
+	case HostKeyFingerprint:
+		if len(options.Fingerprints) == 0 {
+			return nil, ErrNoFingerprints
+		}
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			fingerprint := ssh.FingerprintSHA256(key)
+			for _, f := range options.Fingerprints {
+				if strings.TrimPrefix(f, "SHA256:") == strings.TrimPrefix(fingerprint, "SHA256:") {
+					return nil
+				}
+			}
+			return fmt.Errorf("%w: %s presented %s", ErrFingerprintMismatch, hostname, fingerprint)
+		}, nil
+	case HostKeyStrict, HostKeyAcceptNew:
+	default:
+		return nil, ErrHostKeyPolicy
+	}
+
+	file, err := knownHostsPath(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := checkKnownHost(file, hostname, remote, key)
+		if errors.Is(err, ErrUnknownHost) && policy == HostKeyAcceptNew {
+			return addKnownHost(file, hostname, key)
+		}
+		return err
+	}, nil
+}
+
+func knownHostsPath(options configuration.SshOptions) (string, error) {
+	if options.KnownHosts != "" {
+		return misc.AbsolutePath(options.KnownHosts)
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// checkKnownHost checks a host key against the known hosts file, read at each connection
+func checkKnownHost(file, hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s (%s)", ErrUnknownHost, hostname, file)
+	}
+
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return err
+	}
+
+	// * the hostname is checked, the remote address is only required to be valid
+	if remote == nil {
+		remote = &net.TCPAddr{}
+	}
+	err = callback(hostname, remote, key)
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return err
+	}
+	if len(keyErr.Want) == 0 {
+		return fmt.Errorf("%w: %s (%s)", ErrUnknownHost, hostname, file)
+	}
+
+	known := []string{}
+	for _, k := range keyErr.Want {
+		known = append(known, fmt.Sprintf("%s:%d", k.Filename, k.Line))
+	}
+	return fmt.Errorf(
+		"%w: %s presented %s, known keys at %s (remove them with \"ssh-keygen -R %s -f %s\" if the change is expected)",
+		ErrHostKeyMismatch, hostname, ssh.FingerprintSHA256(key), strings.Join(known, ", "), knownhosts.Normalize(hostname), file,
+	)
+}
+
+// knownHostAlgorithms returns the types of the keys known for a host, so the server presents one of them.
+// Without it, a server presenting another key type than the known one is considered as a key mismatch.
+func knownHostAlgorithms(file, hostname string) []string {
+	callback, err := knownhosts.New(file)
+	if err != nil {
+		return nil
+	}
+
+	// * a throwaway key never matches: the error lists the keys known for the host
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(callback(hostname, &net.TCPAddr{}, probe), &keyErr) {
+		return nil
+	}
+
+	// * nil keeps the default algorithms for unknown hosts
+	var algorithms []string
+	for _, k := range keyErr.Want {
+		algorithms = append(algorithms, k.Key.Type())
+	}
+	return algorithms
+}
+
+func addKnownHost(file, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+	return err
+}
+
+// sshAddress returns the "host:port" address of an SSH repository URL
+func sshAddress(url string) (string, *transport.Endpoint, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return "", nil, err
+	}
+	if endpoint.Protocol != "ssh" {
+		return "", nil, ErrNotSSH
+	}
+
+	port := endpoint.Port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(endpoint.Host, strconv.Itoa(port)), endpoint, nil
+}
+
+// ScanHostKey retrieves the host key of the repository SSH server, without authenticating
+func ScanHostKey(config configuration.Git) (HostKey, error) {
+	address, endpoint, err := sshAddress(config.URL)
+	if err != nil {
+		return HostKey{}, err
+	}
+
+	var key ssh.PublicKey
+	conn, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User: endpoint.User,
+		HostKeyCallback: func(hostname string, remote net.Addr, k ssh.PublicKey) error {
+			key = k
+			// * stop the handshake, only the host key is needed
+			return errors.New("host key retrieved")
+		},
+		Timeout: 30 * time.Second,
+	})
+	if conn != nil {
+		conn.Close()
+	}
+	if key == nil {
+		return HostKey{}, fmt.Errorf("failed to retrieve host key of %s: %v", address, err)
+	}
+
+	hostKey := HostKey{
+		Host:        address,
+		Type:        key.Type(),
+		Fingerprint: ssh.FingerprintSHA256(key),
+		key:         key,
+	}
+
+	options, err := sshOptions(config.SSH)
+	if err != nil {
+		return hostKey, err
+	}
+	file, err := knownHostsPath(options)
+	if err != nil {
+		return hostKey, err
+	}
+
+	err = checkKnownHost(file, address, nil, key)
+	if errors.Is(err, ErrUnknownHost) {
+		return hostKey, nil
+	}
+	hostKey.Known = err == nil
+	return hostKey, err
+}
+
+// TrustHostKey records a host key retrieved by ScanHostKey into the known hosts file
+func TrustHostKey(config configuration.Git, hostKey HostKey) error {
+	options, err := sshOptions(config.SSH)
+	if err != nil {
+		return err
+	}
+	file, err := knownHostsPath(options)
+	if err != nil {
+		return err
+	}
+
+	return addKnownHost(file, hostKey.Host, hostKey.key)
+}
@@ -0,0 +1,73 @@
+package git
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"path"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestCheckKnownHost(t *testing.T) {
+	known, other := newHostKey(t), newHostKey(t)
+
+	for _, tc := range []struct {
+		name     string
+		known    map[string]ssh.PublicKey
+		hostname string
+		key      ssh.PublicKey
+		err      error
+	}{
+		{"missing file", nil, "github.com:22", known, ErrUnknownHost},
+		{"known key", map[string]ssh.PublicKey{"github.com:22": known}, "github.com:22", known, nil},
+		{"known key on another port", map[string]ssh.PublicKey{"host:2222": known}, "host:2222", known, nil},
+		{"unknown host", map[string]ssh.PublicKey{"github.com:22": known}, "gitlab.com:22", known, ErrUnknownHost},
+		{"port is part of the host", map[string]ssh.PublicKey{"host:22": known}, "host:2222", known, ErrUnknownHost},
+		{"changed key", map[string]ssh.PublicKey{"github.com:22": known}, "github.com:22", other, ErrHostKeyMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file := path.Join(t.TempDir(), "known_hosts")
+			for host, key := range tc.known {
+				if err := addKnownHost(file, host, key); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := checkKnownHost(file, tc.hostname, nil, tc.key); !errors.Is(err, tc.err) {
+				t.Errorf("checkKnownHost(%s) = %v, want %v", tc.hostname, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestKnownHostAlgorithms(t *testing.T) {
+	file := path.Join(t.TempDir(), "known_hosts")
+	if err := addKnownHost(file, "github.com:22", newHostKey(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := knownHostAlgorithms(file, "github.com:22"); !reflect.DeepEqual(got, []string{ssh.KeyAlgoED25519}) {
+		t.Errorf("knownHostAlgorithms() = %v, want [%s]", got, ssh.KeyAlgoED25519)
+	}
+	// * unknown hosts keep the default algorithms
+	if got := knownHostAlgorithms(file, "gitlab.com:22"); got != nil {
+		t.Errorf("knownHostAlgorithms() = %v, want nil", got)
+	}
+}
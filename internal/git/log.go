@@ -0,0 +1,156 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// LogEntry is a commit of the repository changing a path
+type LogEntry struct {
+	Hash    string
+	Author  string
+	Email   string
+	Host    string
+	Date    time.Time
+	Subject string
+	// Stats only contains the files under the requested path
+	Stats object.FileStats
+	// Patch is the unified diff of the files under the requested path, if requested
+	Patch string
+}
+
+// hostPattern matches the host in subjects generated by a commit template (E.g: "save(laptop): ..." with the default one).
+// It's nil if the template doesn't have the host or doesn't delimit it.
+func hostPattern(tmpl string) *regexp.Regexp {
+	const hostMarker, otherMarker = "\x00host\x00", "\x00other\x00"
+
+	other := []string{otherMarker}
+	msg, err := commitMessage(tmpl, CommitData{
+		Host:    hostMarker,
+		Added:   other,
+		Updated: other,
+		Removed: other,
+		Items:   other,
+		Summary: otherMarker,
+	})
+	if err != nil {
+		return nil
+	}
+
+	subject := strings.SplitN(msg, "\n", 2)[0]
+	i := strings.Index(subject, hostMarker)
+	if i < 0 {
+		return nil
+	}
+	prefix, suffix := subject[:i], subject[i+len(hostMarker):]
+	if j := strings.Index(suffix, "\x00"); j >= 0 {
+		suffix = suffix[:j]
+	}
+	if suffix == "" {
+		return nil
+	}
+
+	// * the subject only starts with the prefix if nothing else comes before the host
+	anchor := "^"
+	if j := strings.LastIndex(prefix, "\x00"); j >= 0 {
+		prefix, anchor = prefix[j+1:], ""
+	}
+
+	return regexp.MustCompile(anchor + regexp.QuoteMeta(prefix) + `(\S+?)` + regexp.QuoteMeta(suffix))
+}
+
+// commitHost returns the host of a subject generated by the commit template, if any
+func commitHost(pattern *regexp.Regexp, subject string) string {
+	if pattern == nil {
+		return ""
+	}
+	if m := pattern.FindStringSubmatch(subject); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
+
+// Log walks the history of the current branch for a path relative to the repository (all files if empty).
+//
+// A path matches itself and the files of a folder.
+// The host is read from subjects generated by the commit template, it's empty for other messages.
+func (r *Repository) Log(p string, patch bool) ([]LogEntry, error) {
+	p = strings.Trim(p, "/")
+	match := func(name string) bool {
+		return p == "" || name == p || strings.HasPrefix(name, p+"/")
+	}
+
+	head, err := r.repository.Head()
+	if err != nil {
+		return nil, err
+	}
+	pattern := hostPattern(r.commitTemplate)
+
+	commits, err := r.repository.Log(&git.LogOptions{
+		From:       head.Hash(),
+		PathFilter: match,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []LogEntry{}
+	err = commits.ForEach(func(c *object.Commit) error {
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+
+		var parentTree *object.Tree
+		if c.NumParents() > 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return err
+			}
+			if parentTree, err = parent.Tree(); err != nil {
+				return err
+			}
+		}
+
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return err
+		}
+
+		filtered := object.Changes{}
+		for _, change := range changes {
+			if match(change.From.Name) || match(change.To.Name) {
+				filtered = append(filtered, change)
+			}
+		}
+
+		diff, err := filtered.Patch()
+		if err != nil {
+			return err
+		}
+
+		subject := strings.SplitN(c.Message, "\n", 2)[0]
+		entry := LogEntry{
+			Hash:    c.Hash.String()[:7],
+			Author:  c.Author.Name,
+			Email:   c.Author.Email,
+			Host:    commitHost(pattern, subject),
+			Date:    c.Author.When,
+			Subject: subject,
+			Stats:   diff.Stats(),
+		}
+		if patch {
+			entry.Patch = diff.String()
+		}
+		entries = append(entries, entry)
+
+		return nil
+	})
+
+	return entries, err
+}
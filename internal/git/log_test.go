@@ -0,0 +1,98 @@
+package git
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestCommitHost(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		tmpl    string
+		subject string
+		want    string
+	}{
+		{"default template", "", "save(laptop): updated .zshrc", "laptop"},
+		{"user message", "", "fix my prompt", ""},
+		{"promote commit", "", "promote(hosts/laptop): .zshrc", ""},
+		{"custom template", "[{{.Host}}] {{join .Items \", \"}}", "[desktop.lan] .zshrc, nvim/", "desktop.lan"},
+		{"host after the summary", "{{.Summary}} (from {{.Host}})", "updated .zshrc (from laptop)", "laptop"},
+		{"undelimited host", "{{.Summary}} {{.Host}}", "updated .zshrc laptop", ""},
+		{"no host", "sync {{join .Items \" \"}}", "sync .zshrc", ""},
+		{"invalid template", "{{.Host", "save(laptop): updated .zshrc", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := commitHost(hostPattern(tc.tmpl), tc.subject); got != tc.want {
+				t.Errorf("commitHost(%q, %q) = %q, want %q", tc.tmpl, tc.subject, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		file, content, msg string
+	}{
+		{".zshrc", "a\n", "save(laptop): added .zshrc"},
+		{"nvim/init.lua", "a\n", "save(laptop): added nvim/"},
+		{".zshrc", "b\n", "my own message"},
+	} {
+		p := path.Join(dir, c.file)
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(c.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(c.file); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Commit(c.msg, &git.CommitOptions{Author: &object.Signature{Name: "a", Email: "a@a", When: time.Now()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &Repository{repository: repo, repoPath: dir}
+	entries, err := r.Log(".zshrc", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Log() returned %d entries, want 2", len(entries))
+	}
+	if entries[0].Subject != "my own message" || entries[0].Host != "" {
+		t.Errorf("first entry = %q from %q, want the user message without host", entries[0].Subject, entries[0].Host)
+	}
+	if entries[1].Host != "laptop" {
+		t.Errorf("second entry host = %q, want laptop", entries[1].Host)
+	}
+	if len(entries[0].Stats) != 1 || entries[0].Stats[0].Name != ".zshrc" {
+		t.Errorf("first entry stats = %v, want .zshrc only", entries[0].Stats)
+	}
+	if entries[0].Patch == "" {
+		t.Error("patch wasn't generated")
+	}
+
+	all, err := r.Log("", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Patch != "" {
+		t.Errorf("Log() of all files returned %d entries, want 3 without patch", len(all))
+	}
+}
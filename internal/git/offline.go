@@ -0,0 +1,158 @@
+package git
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var (
+	// NoErrQueued is returned by PushChanges when the commit couldn't be pushed because the remote is unreachable
+	NoErrQueued       = errors.New("commit queued until the remote is reachable")
+	ErrOfflineNoClone = errors.New("repository isn't cloned yet and can't be cloned offline")
+)
+
+// RepositoryStatus describes the local repository compared to the last known state of its remote
+type RepositoryStatus struct {
+	Branch string
+	// Queued is the number of local commits not pushed yet
+	Queued int
+	// Changes is the number of uncommitted files
+	Changes int
+	Offline bool
+}
+
+// isUnreachable checks if an error is caused by the remote being unreachable (E.g: no network, DNS failure).
+// TLS verification failures aren't, the remote must not be trusted.
+func isUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// * url.Error implements net.Error whatever the error it wraps is
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// * some transports only keep the message of the network error
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"no such host",
+		"network is unreachable",
+		"connection refused",
+		"connection timed out",
+		"i/o timeout",
+		"no route to host",
+		"temporary failure in name resolution",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Repository) Offline() bool {
+	return r.offline
+}
+
+// Status reports uncommitted changes and commits not pushed yet, without contacting the remote
+func (r *Repository) Status() (RepositoryStatus, error) {
+	status := RepositoryStatus{Offline: r.offline}
+
+	w, err := r.repository.Worktree()
+	if err != nil {
+		return status, err
+	}
+	s, err := w.Status()
+	if err != nil {
+		return status, err
+	}
+	status.Changes = len(s)
+
+	head, err := r.repository.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return status, nil
+		}
+		return status, err
+	}
+	status.Branch = head.Name().Short()
+
+	status.Queued, err = r.queuedCommits(head)
+	return status, err
+}
+
+// queuedCommits counts the commits of the current branch not known by the remote.
+// Commits reachable from any remote branch are pushed, E.g: a new local branch forked from a pushed one.
+func (r *Repository) queuedCommits(head *plumbing.Reference) (int, error) {
+	pushed := map[plumbing.Hash]bool{}
+	refs, err := r.repository.References()
+	if err != nil {
+		return 0, err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		// * "origin/HEAD" is a symbolic reference to one of the branches
+		if ref.Type() != plumbing.HashReference || !strings.HasPrefix(ref.Name().String(), "refs/remotes/origin/") {
+			return nil
+		}
+
+		c, err := r.repository.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		// * commits already marked are skipped with their parents
+		return object.NewCommitPreorderIter(c, pushed, nil).ForEach(func(c *object.Commit) error {
+			pushed[c.Hash] = true
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	commits, err := r.repository.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return 0, err
+	}
+
+	queued := 0
+	err = commits.ForEach(func(c *object.Commit) error {
+		if !pushed[c.Hash] {
+			queued++
+		}
+		return nil
+	})
+
+	return queued, err
+}
+
+// pushQueued pushes local commits left by previous offline runs
+func (r *Repository) pushQueued(repo *git.Repository) error {
+	head, err := repo.Head()
+	if err != nil {
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	queued, err := r.queuedCommits(head)
+	if err != nil || queued == 0 {
+		return err
+	}
+
+	return r.push(repo, head.Name().Short())
+}
@@ -0,0 +1,99 @@
+package git
+
+import (
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestQueuedCommits(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// remotes maps remote branches to the number of commits of the current branch they contain
+		remotes map[string]int
+		commits int
+		want    int
+	}{
+		{name: "never pushed", commits: 3, want: 3},
+		{name: "up to date", remotes: map[string]int{"main": 3}, commits: 3, want: 0},
+		{name: "ahead of its remote branch", remotes: map[string]int{"main": 1}, commits: 3, want: 2},
+		{name: "new branch forked from a pushed one", remotes: map[string]int{"other": 2}, commits: 3, want: 1},
+		{name: "most recent remote branch wins", remotes: map[string]int{"main": 1, "other": 2}, commits: 3, want: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, err := git.PlainInit(t.TempDir(), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := repo.Worktree()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hashes := []plumbing.Hash{}
+			for i := 0; i < tc.commits; i++ {
+				h, err := w.Commit("commit", &git.CommitOptions{
+					Author: &object.Signature{Name: "a", Email: "a@a", When: time.Now()},
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				hashes = append(hashes, h)
+			}
+			for branch, n := range tc.remotes {
+				ref := plumbing.NewHashReference(plumbing.NewRemoteReferenceName("origin", branch), hashes[n-1])
+				if err := repo.Storer.SetReference(ref); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// * "origin/HEAD" is symbolic and must be skipped
+			if err := repo.Storer.SetReference(plumbing.NewSymbolicReference("refs/remotes/origin/HEAD", "refs/remotes/origin/main")); err != nil {
+				t.Fatal(err)
+			}
+
+			head, err := repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &Repository{repository: repo}
+			got, err := r.queuedCommits(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("queuedCommits() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsUnreachable(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"no error", nil, false},
+		{"dial error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"dns error", &net.DNSError{Err: "no such host", Name: "github.com"}, true},
+		{"http dial error", &url.Error{Op: "Get", URL: "https://github.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("refused")}}, true},
+		{"message only", errors.New("dial tcp: lookup github.com: Temporary failure in name resolution"), true},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://github.com", Err: x509.UnknownAuthorityError{}}, false},
+		{"invalid hostname", &url.Error{Op: "Get", URL: "https://github.com", Err: x509.HostnameError{Certificate: &x509.Certificate{}, Host: "github.com"}}, false},
+		{"tls message", errors.New("x509: certificate signed by unknown authority"), false},
+		{"authentication", errors.New("authentication required"), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isUnreachable(tc.err); got != tc.want {
+				t.Errorf("isUnreachable(%v) = %t, want %t", tc.err, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+const (
+	PullFastForward = "ff-only"
+	PullMerge       = "merge"
+	PullRebase      = "rebase"
+	PullSkip        = "skip"
+)
+
+var (
+	ErrPullStrategy    = errors.New("pull strategy must be one of ff-only, merge, rebase or skip")
+	ErrDetachedHead    = errors.New("repository HEAD is detached")
+	ErrDiverged        = errors.New("local and remote branches have diverged and can't be fast-forwarded")
+	ErrGitNotAvailable = errors.New("git is required to merge or rebase remote changes")
+)
+
+// ConflictError is returned when remote changes can't be merged or rebased automatically
+type ConflictError struct {
+	Strategy string
+	// Paths are the conflicted files relative to the repository
+	Paths []string
+	// Output is the git command output
+	Output string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s stopped on conflicts in: %s", e.Strategy, strings.Join(e.Paths, ", "))
+}
+
+// NextSteps describes how to resolve the conflicts inside the repository
+func (e *ConflictError) NextSteps(repoPath string) []string {
+	steps := []string{
+		fmt.Sprintf("resolve the conflicts in %s and stage them with \"git add\"", repoPath),
+	}
+
+	switch e.Strategy {
+	case PullRebase:
+		steps = append(steps, "continue with \"git rebase --continue\" or give up with \"git rebase --abort\"")
+	case PullMerge:
+		steps = append(steps, "commit with \"git commit --no-edit\" or give up with \"git merge --abort\"")
+	}
+
+	// * auto-stashed changes are kept in the stash when they can't be applied back
+	return append(steps, "check \"git stash list\" for local changes stashed before the pull")
+}
+
+// pull retrieves remote changes and integrates them into the current branch with the configured strategy.
+//
+// Local changes are stashed before merging or rebasing and applied back afterwards.
+// Merging and rebasing rely on the git binary. Without it, only fast-forwards of a clean worktree are supported.
+func (r *Repository) pull(repo *git.Repository) error {
+	strategy := r.pullStrategy
+	if strategy == "" {
+		strategy = PullFastForward
+	}
+
+	switch strategy {
+	case PullSkip:
+		return nil
+	case PullFastForward, PullMerge, PullRebase:
+	default:
+		return ErrPullStrategy
+	}
+
+	_, lookErr := exec.LookPath("git")
+	if lookErr != nil && strategy != PullFastForward {
+		return ErrGitNotAvailable
+	}
+
+	err := repo.Fetch(&git.FetchOptions{Auth: r.auth})
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil
+	}
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		// * nothing was committed yet
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !head.Name().IsBranch() {
+		return ErrDetachedHead
+	}
+
+	upstream := fmt.Sprintf("origin/%s", head.Name().Short())
+	remote, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", head.Name().Short()), true)
+	if err != nil {
+		// * the branch hasn't been pushed yet
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	upToDate, err := isAncestor(repo, remote.Hash(), head.Hash())
+	if err != nil {
+		return err
+	}
+	if upToDate {
+		return nil
+	}
+
+	if r.signer != nil && r.signer.verify {
+		if err := r.verifyIncoming(repo, head.Hash(), remote.Hash()); err != nil {
+			return err
+		}
+	}
+
+	if lookErr != nil {
+		w, err := repo.Worktree()
+		if err != nil {
+			return err
+		}
+		if err := w.Pull(&git.PullOptions{Auth: r.auth}); err != nil && err != git.NoErrAlreadyUpToDate {
+			return err
+		}
+		return nil
+	}
+
+	// * merging or rebasing diverged branches creates commits, they're signed like the ones of config-mapper
+	var args []string
+	if strategy != PullFastForward {
+		fastForward, err := isAncestor(repo, head.Hash(), remote.Hash())
+		if err != nil {
+			return err
+		}
+		if !fastForward {
+			if args, err = r.signer.gitArgs(); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strategy {
+	case PullFastForward:
+		args = append(args, "merge", "--ff-only", "--autostash", upstream)
+	case PullMerge:
+		args = append(args, "merge", "--no-edit", "--autostash", upstream)
+	case PullRebase:
+		args = append(args, "rebase", "--autostash", upstream)
+	}
+
+	out, err := r.gitCommand(args...)
+	if err == nil {
+		return nil
+	}
+
+	conflicts, cerr := r.gitCommand("diff", "--name-only", "--diff-filter=U")
+	if cerr == nil && strings.TrimSpace(conflicts) != "" {
+		return &ConflictError{
+			Strategy: strategy,
+			Paths:    strings.Split(strings.TrimSpace(conflicts), "\n"),
+			Output:   out,
+		}
+	}
+	if strategy == PullFastForward {
+		return fmt.Errorf("%w, use the \"merge\" or \"rebase\" pull strategy: %s", ErrDiverged, strings.TrimSpace(out))
+	}
+
+	return fmt.Errorf("failed to %s %s: %s", strategy, upstream, strings.TrimSpace(out))
+}
+
+// isAncestor checks if the commit a is an ancestor of (or the same commit as) b
+func isAncestor(repo *git.Repository, a, b plumbing.Hash) (bool, error) {
+	if a == b {
+		return true, nil
+	}
+
+	ca, err := repo.CommitObject(a)
+	if err != nil {
+		return false, err
+	}
+	cb, err := repo.CommitObject(b)
+	if err != nil {
+		return false, err
+	}
+
+	return ca.IsAncestor(cb)
+}
+
+// gitCommand runs a git command inside the repository and returns its output.
+//
+// The configured author is used for commits created by the command (E.g: merge commits, rebased commits).
+func (r *Repository) gitCommand(args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", r.repoPath}, args...)...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Env = os.Environ()
+	if r.author.name != "" && r.author.email != "" {
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("GIT_AUTHOR_NAME=%s", r.author.name),
+			fmt.Sprintf("GIT_AUTHOR_EMAIL=%s", r.author.email),
+			fmt.Sprintf("GIT_COMMITTER_NAME=%s", r.author.name),
+			fmt.Sprintf("GIT_COMMITTER_EMAIL=%s", r.author.email),
+		)
+	}
+
+	err := cmd.Run()
+	return out.String(), err
+}
@@ -0,0 +1,128 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// runGit runs a git command inside a directory
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=a", "-c", "user.email=a@a"}, args...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+}
+
+// commitFile writes a file and commits it with git
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", name)
+	runGit(t, dir, "commit", "-q", "-m", "update "+name)
+}
+
+// newDivergedRepository returns a repository whose branch diverged from its remote branch.
+// The remote changed remoteFile and the local branch changed localFile.
+func newDivergedRepository(t *testing.T, strategy, remoteFile, localFile string) *Repository {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't available")
+	}
+
+	dir := t.TempDir()
+	remote, other, local := path.Join(dir, "remote"), path.Join(dir, "other"), path.Join(dir, "local")
+	runGit(t, dir, "init", "-q", "--bare", "-b", "main", remote)
+	runGit(t, dir, "clone", "-q", remote, other)
+	runGit(t, other, "checkout", "-q", "-b", "main")
+	commitFile(t, other, ".zshrc", "base\n")
+	runGit(t, other, "push", "-q", "origin", "main")
+	// * the repository is cloned by config-mapper
+	repo, err := git.PlainClone(local, false, &git.CloneOptions{URL: remote})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitFile(t, other, remoteFile, "remote\n")
+	runGit(t, other, "push", "-q", "origin", "main")
+	commitFile(t, local, localFile, "local\n")
+
+	return &Repository{repository: repo, repoPath: local, pullStrategy: strategy, author: author{name: "a", email: "a@a"}}
+}
+
+func TestPull(t *testing.T) {
+	for _, tc := range []struct {
+		strategy string
+		parents  int
+		err      error
+	}{
+		{strategy: PullFastForward, err: ErrDiverged},
+		{strategy: PullMerge, parents: 2},
+		{strategy: PullRebase, parents: 1},
+		{strategy: PullSkip, parents: 1},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			r := newDivergedRepository(t, tc.strategy, ".vimrc", ".bashrc")
+
+			err := r.pull(r.repository)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("pull() = %v, want %v", err, tc.err)
+			}
+			if tc.err != nil {
+				return
+			}
+
+			head, err := r.repository.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			commit, err := r.repository.CommitObject(head.Hash())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if commit.NumParents() != tc.parents {
+				t.Errorf("HEAD has %d parents, want %d", commit.NumParents(), tc.parents)
+			}
+			if _, err := os.Stat(path.Join(r.repoPath, ".vimrc")); (err == nil) != (tc.strategy != PullSkip) {
+				t.Errorf("remote file pulled: %t, want %t", err == nil, tc.strategy != PullSkip)
+			}
+		})
+	}
+}
+
+func TestPullConflict(t *testing.T) {
+	for _, strategy := range []string{PullMerge, PullRebase} {
+		t.Run(strategy, func(t *testing.T) {
+			r := newDivergedRepository(t, strategy, ".zshrc", ".zshrc")
+
+			var conflict *ConflictError
+			if err := r.pull(r.repository); !errors.As(err, &conflict) {
+				t.Fatalf("pull() = %v, want a ConflictError", err)
+			}
+			if conflict.Strategy != strategy || !reflect.DeepEqual(conflict.Paths, []string{".zshrc"}) {
+				t.Errorf("ConflictError = %+v, want %s conflicts in .zshrc", conflict, strategy)
+			}
+			if steps := conflict.NextSteps(r.repoPath); len(steps) != 3 {
+				t.Errorf("NextSteps() = %q, want 3 steps", steps)
+			}
+		})
+	}
+}
+
+func TestPullStrategy(t *testing.T) {
+	r := &Repository{pullStrategy: "squash"}
+	if err := r.pull(nil); !errors.Is(err, ErrPullStrategy) {
+		t.Errorf("pull() = %v, want %v", err, ErrPullStrategy)
+	}
+}
@@ -0,0 +1,448 @@
+package git
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshSignatureMagic     = "SSHSIG"
+	sshSignatureNamespace = "git"
+	sshSignatureBegin     = "-----BEGIN SSH SIGNATURE-----"
+	sshSignatureEnd       = "-----END SSH SIGNATURE-----"
+)
+
+var (
+	ErrSigningKeys        = errors.New("only one of gpg and ssh signing keys can be configured")
+	ErrGPGKeyNotFound     = errors.New("gpg key not found in the key file")
+	ErrNoTrustedKeys      = errors.New("signatures can't be verified without a signing key or trusted keys")
+	ErrUnsignedCommit     = errors.New("commit isn't signed")
+	ErrUntrustedSignature = errors.New("commit isn't signed by a trusted key")
+	ErrGitSigning         = errors.New("merge and rebase pull strategies can only sign commits with an ssh key without passphrase, use the ff-only pull strategy or another signing key")
+)
+
+// signer signs commits and verifies signatures of remote commits
+type signer struct {
+	gpg *openpgp.Entity
+	ssh ssh.Signer
+	// sshKeyFile is given to git to sign the commits it creates, only set for keys without passphrase
+	sshKeyFile string
+	// trustedGPG and trustedSSH are the public keys accepted when verifying signatures
+	trustedGPG openpgp.EntityList
+	trustedSSH []ssh.PublicKey
+	verify     bool
+}
+
+// sshSignature is the SSHSIG signature blob (https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig)
+type sshSignature struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the SSHSIG blob actually signed by the key
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+func newSigner(config configuration.Signing) (*signer, error) {
+	s := &signer{verify: config.VerifySignatures}
+
+	if config.GPG.KeyFile != "" && config.SSH.PrivateKey != "" {
+		return nil, ErrSigningKeys
+	}
+
+	if config.GPG.KeyFile != "" {
+		entity, err := readGPGKey(config.GPG)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gpg signing key: %v", err)
+		}
+		s.gpg = entity
+		s.trustedGPG = append(s.trustedGPG, entity)
+	}
+
+	if config.SSH.PrivateKey != "" {
+		key, err := readSSHKey(config.SSH)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ssh signing key: %v", err)
+		}
+		s.ssh = key
+		s.trustedSSH = append(s.trustedSSH, key.PublicKey())
+		if config.SSH.Passphrase == "" {
+			if s.sshKeyFile, err = misc.AbsolutePath(config.SSH.PrivateKey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, p := range config.TrustedKeys {
+		if err := s.addTrustedKeys(p); err != nil {
+			return nil, fmt.Errorf("failed to read trusted keys %s: %v", p, err)
+		}
+	}
+
+	if s.verify && len(s.trustedGPG) == 0 && len(s.trustedSSH) == 0 {
+		return nil, ErrNoTrustedKeys
+	}
+
+	return s, nil
+}
+
+// gitArgs returns the git options signing the commits created by git commands (E.g: merge commits, rebased commits).
+// git can't use a gpg key file nor decrypt an ssh key, ErrGitSigning is returned for these keys.
+func (s *signer) gitArgs() ([]string, error) {
+	if s == nil || (s.gpg == nil && s.ssh == nil) {
+		return nil, nil
+	}
+	if s.sshKeyFile == "" {
+		return nil, ErrGitSigning
+	}
+
+	return []string{"-c", "gpg.format=ssh", "-c", "user.signingkey=" + s.sshKeyFile, "-c", "commit.gpgsign=true"}, nil
+}
+
+// readGPGKey reads an armored private key and decrypts it if needed
+func readGPGKey(config configuration.GPGSigning) (*openpgp.Entity, error) {
+	keyFile, err := misc.AbsolutePath(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entities, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var entity *openpgp.Entity
+	for _, e := range entities {
+		if e.PrivateKey != nil && (config.KeyID == "" || matchGPGKeyID(e, config.KeyID)) {
+			entity = e
+			break
+		}
+	}
+	if entity == nil {
+		return nil, fmt.Errorf("%w: %s", ErrGPGKeyNotFound, config.KeyID)
+	}
+
+	passphrase := []byte(config.Passphrase)
+	if entity.PrivateKey.Encrypted {
+		if err := entity.PrivateKey.Decrypt(passphrase); err != nil {
+			return nil, err
+		}
+	}
+	for _, sub := range entity.Subkeys {
+		if sub.PrivateKey != nil && sub.PrivateKey.Encrypted {
+			if err := sub.PrivateKey.Decrypt(passphrase); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return entity, nil
+}
+
+// matchGPGKeyID checks if a key ID (short, long or fingerprint) belongs to the primary key or a subkey of an entity
+func matchGPGKeyID(e *openpgp.Entity, id string) bool {
+	id = strings.ToUpper(strings.TrimPrefix(strings.ReplaceAll(id, " ", ""), "0x"))
+	match := func(fingerprint []byte) bool {
+		return strings.HasSuffix(fmt.Sprintf("%X", fingerprint), id)
+	}
+
+	if match(e.PrimaryKey.Fingerprint) {
+		return true
+	}
+	for _, sub := range e.Subkeys {
+		if match(sub.PublicKey.Fingerprint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func readSSHKey(config configuration.SSHSigning) (ssh.Signer, error) {
+	return loadSSHKey(configuration.Ssh{PrivateKey: config.PrivateKey, Passphrase: config.Passphrase})
+}
+
+// addTrustedKeys reads either an armored GPG key ring or SSH public keys from a file
+func (s *signer) addTrustedKeys(p string) error {
+	p, err := misc.AbsolutePath(p)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Contains(b, []byte("-----BEGIN PGP")) {
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		s.trustedGPG = append(s.trustedGPG, entities...)
+		return nil
+	}
+
+	for len(bytes.TrimSpace(b)) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(b)
+		if err != nil {
+			return err
+		}
+		s.trustedSSH = append(s.trustedSSH, key)
+		b = rest
+	}
+
+	return nil
+}
+
+// commit records the staged changes with the host trailer and signs the commit if a signing key is configured
+func (r *Repository) commit(w *git.Worktree, msg string) (plumbing.Hash, error) {
+	opts := &git.CommitOptions{Author: r.GetAuthor()}
+	if r.signer != nil {
+		opts.SignKey = r.signer.gpg
+	}
+
+	hash, err := w.Commit(msg, opts)
+	if err != nil || r.signer == nil || r.signer.ssh == nil {
+		return hash, err
+	}
+
+	// * go-git only signs with GPG keys: the commit is signed afterwards and HEAD moved to the signed commit
+	return r.signSSH(hash)
+}
+
+// signSSH replaces the HEAD commit with a copy signed with the SSH signing key
+func (r *Repository) signSSH(hash plumbing.Hash) (plumbing.Hash, error) {
+	commit, err := r.repository.CommitObject(hash)
+	if err != nil {
+		return hash, err
+	}
+
+	unsigned := r.repository.Storer.NewEncodedObject()
+	if err := commit.EncodeWithoutSignature(unsigned); err != nil {
+		return hash, err
+	}
+	reader, err := unsigned.Reader()
+	if err != nil {
+		return hash, err
+	}
+	defer reader.Close()
+
+	commit.PGPSignature, err = sshSign(r.signer.ssh, reader)
+	if err != nil {
+		return hash, fmt.Errorf("failed to sign commit: %v", err)
+	}
+
+	signed := r.repository.Storer.NewEncodedObject()
+	if err := commit.Encode(signed); err != nil {
+		return hash, err
+	}
+	signedHash, err := r.repository.Storer.SetEncodedObject(signed)
+	if err != nil {
+		return hash, err
+	}
+
+	head, err := r.repository.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return hash, err
+	}
+	name := plumbing.HEAD
+	if head.Type() == plumbing.SymbolicReference {
+		name = head.Target()
+	}
+
+	return signedHash, r.repository.Storer.SetReference(plumbing.NewHashReference(name, signedHash))
+}
+
+// sshSign creates an armored SSHSIG signature of a message, as "ssh-keygen -Y sign -n git" does
+func sshSign(key ssh.Signer, message io.Reader) (string, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return "", err
+	}
+
+	data := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Hash:          h.Sum(nil),
+	})...)
+
+	var sig *ssh.Signature
+	var err error
+	// * SHA-1 RSA signatures are refused by git
+	if algSigner, ok := key.(ssh.AlgorithmSigner); ok && key.PublicKey().Type() == ssh.KeyAlgoRSA {
+		sig, err = algSigner.SignWithAlgorithm(rand.Reader, data, ssh.SigAlgoRSASHA2512)
+	} else {
+		sig, err = key.Sign(rand.Reader, data)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	blob := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignature{
+		Version:       1,
+		PublicKey:     key.PublicKey().Marshal(),
+		Namespace:     sshSignatureNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(sig),
+	})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var armored strings.Builder
+	armored.WriteString(sshSignatureBegin + "\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n" + sshSignatureEnd + "\n")
+
+	return armored.String(), nil
+}
+
+// sshVerify checks an armored SSHSIG signature of a message and returns the signing key
+func sshVerify(armored string, message io.Reader) (ssh.PublicKey, error) {
+	encoded := strings.TrimSpace(armored)
+	if !strings.HasPrefix(encoded, sshSignatureBegin) || !strings.HasSuffix(encoded, sshSignatureEnd) {
+		return nil, errors.New("invalid ssh signature armor")
+	}
+	encoded = strings.Join(strings.Fields(encoded[len(sshSignatureBegin):len(encoded)-len(sshSignatureEnd)]), "")
+
+	blob, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(blob, []byte(sshSignatureMagic)) {
+		return nil, errors.New("invalid ssh signature")
+	}
+
+	var signature sshSignature
+	if err := ssh.Unmarshal(blob[len(sshSignatureMagic):], &signature); err != nil {
+		return nil, err
+	}
+	if signature.Version != 1 || signature.Namespace != sshSignatureNamespace {
+		return nil, fmt.Errorf("unsupported ssh signature version %d or namespace %q", signature.Version, signature.Namespace)
+	}
+
+	key, err := ssh.ParsePublicKey(signature.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(signature.Signature, &sig); err != nil {
+		return nil, err
+	}
+
+	var h hash.Hash
+	switch signature.HashAlgorithm {
+	case "sha512":
+		h = sha512.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return nil, fmt.Errorf("unsupported ssh signature hash algorithm %q", signature.HashAlgorithm)
+	}
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+
+	data := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     signature.Namespace,
+		Reserved:      signature.Reserved,
+		HashAlgorithm: signature.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+
+	return key, key.Verify(data, &sig)
+}
+
+// verifyCommit checks that a commit is signed by one of the trusted keys
+func (s *signer) verifyCommit(c *object.Commit) error {
+	if c.PGPSignature == "" {
+		return fmt.Errorf("%w: %s", ErrUnsignedCommit, c.Hash)
+	}
+
+	unsigned := &plumbing.MemoryObject{}
+	if err := c.EncodeWithoutSignature(unsigned); err != nil {
+		return err
+	}
+	message, err := unsigned.Reader()
+	if err != nil {
+		return err
+	}
+	defer message.Close()
+
+	if strings.HasPrefix(strings.TrimSpace(c.PGPSignature), sshSignatureBegin) {
+		key, err := sshVerify(c.PGPSignature, message)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrUntrustedSignature, c.Hash, err)
+		}
+		for _, trusted := range s.trustedSSH {
+			if bytes.Equal(trusted.Marshal(), key.Marshal()) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %s: signed by %s", ErrUntrustedSignature, c.Hash, ssh.FingerprintSHA256(key))
+	}
+
+	if _, err := openpgp.CheckArmoredDetachedSignature(s.trustedGPG, message, strings.NewReader(c.PGPSignature), nil); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrUntrustedSignature, c.Hash, err)
+	}
+
+	return nil
+}
+
+// verifyIncoming verifies the signatures of the commits of a remote branch not yet in the local branch
+func (r *Repository) verifyIncoming(repo *git.Repository, local, remote plumbing.Hash) error {
+	known := map[plumbing.Hash]bool{}
+	commits, err := repo.Log(&git.LogOptions{From: local})
+	if err != nil {
+		return err
+	}
+	if err := commits.ForEach(func(c *object.Commit) error {
+		known[c.Hash] = true
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	commits, err = repo.Log(&git.LogOptions{From: remote})
+	if err != nil {
+		return err
+	}
+
+	return commits.ForEach(func(c *object.Commit) error {
+		if known[c.Hash] {
+			return nil
+		}
+		return r.signer.verifyCommit(c)
+	})
+}
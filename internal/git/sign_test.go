@@ -0,0 +1,157 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"golang.org/x/crypto/ssh"
+)
+
+// generateSSHKey creates a key pair with ssh-keygen and returns the private key path
+func generateSSHKey(t *testing.T, keyType, passphrase string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen isn't available")
+	}
+
+	p := path.Join(t.TempDir(), "id_"+keyType)
+	args := []string{"-q", "-t", keyType, "-N", passphrase, "-C", "test", "-f", p}
+	if keyType == "rsa" {
+		args = append(args, "-b", "2048")
+	}
+	if out, err := exec.Command("ssh-keygen", args...).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen failed: %v: %s", err, out)
+	}
+	return p
+}
+
+func TestSSHSignature(t *testing.T) {
+	message := "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\nauthor a <a@a> 0 +0000\ncommitter a <a@a> 0 +0000\n\nsave(host): updated .zshrc\n"
+
+	for _, keyType := range []string{"ed25519", "rsa", "ecdsa"} {
+		t.Run(keyType, func(t *testing.T) {
+			keyFile := generateSSHKey(t, keyType, "")
+			key, err := loadSSHKey(configuration.Ssh{PrivateKey: keyFile})
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := path.Dir(keyFile)
+
+			armored, err := sshSign(key, strings.NewReader(message))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			t.Run("round trip", func(t *testing.T) {
+				signer, err := sshVerify(armored, strings.NewReader(message))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(signer.Marshal(), key.PublicKey().Marshal()) {
+					t.Error("signature verified with another key than the signing one")
+				}
+				if _, err := sshVerify(armored, strings.NewReader(message+"tampered")); err == nil {
+					t.Error("signature of a tampered message verified")
+				}
+			})
+
+			t.Run("verified by ssh-keygen", func(t *testing.T) {
+				allowed := path.Join(dir, "allowed_signers")
+				line := "test@config-mapper " + string(ssh.MarshalAuthorizedKey(key.PublicKey()))
+				if err := os.WriteFile(allowed, []byte(line), 0600); err != nil {
+					t.Fatal(err)
+				}
+				signature := path.Join(dir, "message.sig")
+				if err := os.WriteFile(signature, []byte(armored), 0600); err != nil {
+					t.Fatal(err)
+				}
+
+				cmd := exec.Command("ssh-keygen", "-Y", "verify", "-f", allowed, "-I", "test@config-mapper", "-n", "git", "-s", signature)
+				cmd.Stdin = strings.NewReader(message)
+				out, err := cmd.CombinedOutput()
+				if err != nil {
+					t.Fatalf("ssh-keygen -Y verify failed: %v: %s", err, out)
+				}
+				if !strings.Contains(string(out), "Good \"git\" signature") {
+					t.Errorf("unexpected ssh-keygen output: %s", out)
+				}
+			})
+
+			t.Run("signed by ssh-keygen", func(t *testing.T) {
+				file := path.Join(dir, "message")
+				if err := os.WriteFile(file, []byte(message), 0600); err != nil {
+					t.Fatal(err)
+				}
+				if out, err := exec.Command("ssh-keygen", "-Y", "sign", "-f", keyFile, "-n", "git", file).CombinedOutput(); err != nil {
+					t.Fatalf("ssh-keygen -Y sign failed: %v: %s", err, out)
+				}
+				signature, err := os.ReadFile(file + ".sig")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				signer, err := sshVerify(string(signature), strings.NewReader(message))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(signer.Marshal(), key.PublicKey().Marshal()) {
+					t.Error("signature verified with another key than the signing one")
+				}
+			})
+		})
+	}
+}
+
+func TestSSHVerifyInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		armored string
+	}{
+		{"no armor", "AAAA"},
+		{"invalid base64", sshSignatureBegin + "\n!!!\n" + sshSignatureEnd},
+		{"not an SSHSIG blob", sshSignatureBegin + "\nQUJD\n" + sshSignatureEnd},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := sshVerify(tc.armored, strings.NewReader("message")); err == nil {
+				t.Error("invalid signature verified")
+			}
+		})
+	}
+}
+
+func TestNewRepositorySigningPullStrategy(t *testing.T) {
+	plain := generateSSHKey(t, "ed25519", "")
+	encrypted := generateSSHKey(t, "ed25519", "secret")
+
+	for _, tc := range []struct {
+		name     string
+		strategy string
+		key      configuration.SSHSigning
+		err      error
+	}{
+		{"ff-only with a passphrase", PullFastForward, configuration.SSHSigning{PrivateKey: encrypted, Passphrase: "secret"}, ErrOfflineNoClone},
+		{"rebase without passphrase", PullRebase, configuration.SSHSigning{PrivateKey: plain}, ErrOfflineNoClone},
+		{"merge with a passphrase", PullMerge, configuration.SSHSigning{PrivateKey: encrypted, Passphrase: "secret"}, ErrGitSigning},
+		{"rebase with a passphrase", PullRebase, configuration.SSHSigning{PrivateKey: encrypted, Passphrase: "secret"}, ErrGitSigning},
+		{"merge without signing", PullMerge, configuration.SSHSigning{}, ErrOfflineNoClone},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := configuration.Git{
+				URL:          path.Join(t.TempDir(), "remote"),
+				PullStrategy: tc.strategy,
+				Signing:      configuration.Signing{SSH: tc.key},
+			}
+			// * the configuration is checked before the repository, which can't be cloned offline
+			if _, err := NewRepository(config, path.Join(t.TempDir(), "repo"), true); !errors.Is(err, tc.err) {
+				t.Errorf("NewRepository() = %v, want %v", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var ErrSnapshotExists = errors.New("snapshot already exists")
+
+// Snapshot is an annotated tag marking a known good state of the repository
+type Snapshot struct {
+	Name        string
+	Commit      string
+	Tagger      string
+	Date        time.Time
+	Description string
+}
+
+// CreateSnapshot tags the current commit with an annotated tag and pushes it
+func (r *Repository) CreateSnapshot(name, description string) error {
+	head, err := r.repository.Head()
+	if err != nil {
+		return err
+	}
+
+	if description == "" {
+		description = name
+	}
+	if _, err := r.repository.CreateTag(name, head.Hash(), &git.CreateTagOptions{
+		Tagger:  r.GetAuthor(),
+		Message: description,
+	}); err != nil {
+		if errors.Is(err, git.ErrTagExists) {
+			return fmt.Errorf("%w: %s", ErrSnapshotExists, name)
+		}
+		return err
+	}
+
+	if r.offline {
+		return NoErrQueued
+	}
+
+	ref := plumbing.NewTagReferenceName(name)
+	err = r.repository.Push(&git.PushOptions{
+		Auth:     r.auth,
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", ref, ref))},
+	})
+	if isUnreachable(err) {
+		return NoErrQueued
+	}
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+
+	return r.authError(err)
+}
+
+// Snapshots lists annotated tags, the most recent first. Lightweight tags aren't snapshots.
+func (r *Repository) Snapshots() ([]Snapshot, error) {
+	tags, err := r.repository.TagObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	err = tags.ForEach(func(t *object.Tag) error {
+		snapshots = append(snapshots, Snapshot{
+			Name:        t.Name,
+			Commit:      t.Target.String()[:7],
+			Tagger:      t.Tagger.Name,
+			Date:        t.Tagger.When,
+			Description: t.Message,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Date.After(snapshots[j].Date)
+	})
+
+	return snapshots, nil
+}
+
+// ExtractRef writes the files of a revision (tag, branch or commit) into a folder, without changing the worktree
+func (r *Repository) ExtractRef(ref, dst string) error {
+	hash, err := r.repository.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", ref, err)
+	}
+
+	// * annotated tags are resolved to their commit
+	commit, err := r.repository.CommitObject(*hash)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	return tree.Files().ForEach(func(f *object.File) error {
+		return writeTreeFile(f, path.Join(dst, f.Name))
+	})
+}
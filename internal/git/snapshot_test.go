@@ -0,0 +1,88 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Repository{repository: repo, repoPath: dir, offline: true, author: author{name: "a", email: "a@a"}}
+
+	commit := func(content string) {
+		if err := os.WriteFile(path.Join(dir, ".zshrc"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(".zshrc"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Commit("update .zshrc", &git.CommitOptions{Author: &object.Signature{Name: "a", Email: "a@a", When: time.Now()}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	commit("before\n")
+	if err := r.CreateSnapshot("before-upgrade", ""); !errors.Is(err, NoErrQueued) {
+		t.Fatalf("CreateSnapshot() = %v, want %v", err, NoErrQueued)
+	}
+	if err := r.CreateSnapshot("before-upgrade", ""); !errors.Is(err, ErrSnapshotExists) {
+		t.Errorf("CreateSnapshot() of an existing tag = %v, want %v", err, ErrSnapshotExists)
+	}
+	commit("after\n")
+	if err := r.CreateSnapshot("after-upgrade", "upgraded zsh"); !errors.Is(err, NoErrQueued) {
+		t.Fatalf("CreateSnapshot() = %v, want %v", err, NoErrQueued)
+	}
+
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("Snapshots() returned %d snapshots, want 2", len(snapshots))
+	}
+	// * tags created within the same second have the same date, their order isn't checked
+	byName := map[string]Snapshot{}
+	for _, s := range snapshots {
+		byName[s.Name] = s
+	}
+	if s := byName["after-upgrade"]; strings.TrimSpace(s.Description) != "upgraded zsh" {
+		t.Errorf("after-upgrade snapshot = %+v", s)
+	}
+	// * descriptions default to the snapshot name
+	if s := byName["before-upgrade"]; strings.TrimSpace(s.Description) != "before-upgrade" || s.Tagger != "a" {
+		t.Errorf("before-upgrade snapshot = %+v", s)
+	}
+	if snapshots[0].Date.Before(snapshots[1].Date) {
+		t.Errorf("snapshots aren't sorted by date: %+v", snapshots)
+	}
+
+	dst := path.Join(t.TempDir(), "ref")
+	if err := r.ExtractRef("before-upgrade", dst); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path.Join(dst, ".zshrc")); string(got) != "before\n" {
+		t.Errorf("extracted .zshrc = %q, want %q", got, "before\n")
+	}
+	if got, _ := os.ReadFile(path.Join(dir, ".zshrc")); string(got) != "after\n" {
+		t.Errorf("worktree .zshrc = %q, the worktree changed", got)
+	}
+
+	if err := r.ExtractRef("unknown", path.Join(t.TempDir(), "unknown")); err == nil {
+		t.Error("expected an error for an unknown revision")
+	}
+}
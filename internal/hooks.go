@@ -0,0 +1,84 @@
+package mapper
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+)
+
+const defaultHookTimeout = 5 * time.Minute
+
+var ErrHookTimeout = errors.New("hook timed out")
+
+// RunHooks runs the commands of a hook event, one after the other, and logs their output.
+//
+// env holds additional "KEY=value" environment variables describing the hook target.
+// The first failing command stops the next ones.
+func RunHooks(h configuration.Hooks, event string, env ...string) error {
+	if len(h.Commands(event)) == 0 {
+		return nil
+	}
+
+	// * installer goroutines call runHooks directly, their output goes to the job writer instead of the logger
+	log.Info("running hooks", "hook", event, "commands", len(h.Commands(event)))
+	var out bytes.Buffer
+	err := runHooks(h, event, env, &out)
+
+	// * the output is always shown when a hook fails
+	if output := strings.TrimRight(out.String(), "\n"); output != "" && (err != nil || viper.GetBool("verbose")) {
+		fmt.Printf("──── %s hook output ────\n%s\n", event, output)
+	}
+
+	return err
+}
+
+// runHooks runs the commands of a hook event with "sh -c" and writes their output into out
+func runHooks(h configuration.Hooks, event string, env []string, out io.Writer) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	storage, _ := misc.AbsolutePath(viper.GetString("storage.location"))
+	env = append([]string{
+		fmt.Sprintf("CONFIG_MAPPER_HOOK=%s", event),
+		fmt.Sprintf("CONFIG_MAPPER_STORAGE=%s", storage),
+	}, env...)
+
+	for _, c := range h.Commands(event) {
+		fmt.Fprintf(out, "$ %s\n", c)
+
+		cmd := exec.Command("sh", "-c", c)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = out
+		cmd.Stderr = out
+		startHookGroup(cmd)
+
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start %s hook %q: %v", event, c, err)
+		}
+
+		timer := time.AfterFunc(timeout, func() {
+			killHook(cmd)
+		})
+		err := cmd.Wait()
+		if !timer.Stop() {
+			return fmt.Errorf("%w after %s: %s hook %q", ErrHookTimeout, timeout, event, c)
+		}
+		if err != nil {
+			return fmt.Errorf("%s hook %q failed: %v", event, c, err)
+		}
+	}
+
+	return nil
+}
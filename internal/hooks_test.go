@@ -0,0 +1,77 @@
+package mapper
+
+import (
+	"bytes"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+)
+
+func TestRunHooks(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		hooks   configuration.Hooks
+		event   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "environment",
+			hooks: configuration.Hooks{PreLoad: []string{`echo "$CONFIG_MAPPER_HOOK $CONFIG_MAPPER_ACTION"`}},
+			event: configuration.HookPreLoad,
+			want:  "pre-load load",
+		},
+		{
+			name:  "other event",
+			hooks: configuration.Hooks{PreLoad: []string{"echo pre-load"}},
+			event: configuration.HookPostLoad,
+			want:  "",
+		},
+		{
+			name:    "failure stops next commands",
+			hooks:   configuration.Hooks{PostSave: []string{"echo first", "exit 3", "echo next"}},
+			event:   configuration.HookPostSave,
+			want:    "first",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runHooks(tc.hooks, tc.event, []string{"CONFIG_MAPPER_ACTION=load"}, &out)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("runHooks() = %v, want error: %t", err, tc.wantErr)
+			}
+			if !strings.Contains(out.String(), tc.want) {
+				t.Errorf("output = %q, want %q", out.String(), tc.want)
+			}
+			if strings.Contains(out.String(), "next") {
+				t.Errorf("commands ran after a failure: %q", out.String())
+			}
+		})
+	}
+}
+
+func TestRunHooksTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("children of hooks aren't killed on windows")
+	}
+
+	// * the background sleep keeps the output open, it must be killed along with the hook
+	hooks := configuration.Hooks{
+		OnChange: []string{"sleep 10 & sleep 10"},
+		Timeout:  200 * time.Millisecond,
+	}
+
+	start := time.Now()
+	err := runHooks(hooks, configuration.HookOnChange, nil, &bytes.Buffer{})
+	if !errors.Is(err, ErrHookTimeout) {
+		t.Errorf("runHooks() = %v, want %v", err, ErrHookTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runHooks() returned after %s", elapsed)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package mapper
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startHookGroup runs a hook in its own process group to kill its children as well on timeout
+func startHookGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killHook kills the process group of a hook
+func killHook(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
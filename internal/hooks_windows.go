@@ -0,0 +1,11 @@
+package mapper
+
+import "os/exec"
+
+// startHookGroup does nothing, process groups can't be killed at once on windows
+func startHookGroup(cmd *exec.Cmd) {}
+
+// killHook kills the hook process, its children are left running
+func killHook(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,410 @@
+package mapper
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+)
+
+var yarnPackage = regexp.MustCompile(`^"(.+)@([^@]+)" has binaries`)
+
+// installedVersions returns every package installed by a package manager with its version
+func installedVersions(pkgManager, bin string) (map[string]string, error) {
+	switch pkgManager {
+	case "brew":
+		return brewVersions(bin)
+	case "apt", "nala":
+		return dpkgVersions()
+	case "cargo":
+		return cargoVersions(bin)
+	case "pip":
+		return pipVersions(bin)
+	case "npm":
+		return npmVersions(bin)
+	case "go":
+		return goVersions(bin)
+	case "pipx":
+		return pipxVersions(bin)
+	case "uv":
+		return uvVersions(bin)
+	case "pnpm":
+		return pnpmVersions(bin)
+	case "yarn":
+		return yarnVersions(bin)
+	case "nix":
+		return nixVersions(bin)
+	default:
+		return nil, ErrPkgManagerUnsupported
+	}
+}
+
+// userPackages returns packages explicitly installed by the user with a package manager.
+//
+// Dependencies pulled by those packages and packages shipped with the system are excluded when possible.
+// Brew packages are returned by userBrew with their sections.
+func userPackages(pkgManager, bin string) ([]string, error) {
+	switch pkgManager {
+	case "apt", "nala":
+		return commandLines("apt-mark", "showmanual")
+	case "pip":
+		pkgs, err := commandLines(bin, "list", "--not-required", "--format=freeze")
+		if err != nil {
+			return nil, err
+		}
+		for i, p := range pkgs {
+			pkgs[i] = strings.SplitN(p, "==", 2)[0]
+		}
+
+		return pkgs, nil
+	case "cargo", "npm", "go", "pipx", "uv", "pnpm", "yarn", "nix":
+		versions, err := installedVersions(pkgManager, bin)
+		if err != nil {
+			return nil, err
+		}
+
+		pkgs := []string{}
+		for p := range versions {
+			// * npm and corepack are shipped with node
+			if pkgManager == "npm" && (p == "npm" || p == "corepack") {
+				continue
+			}
+			pkgs = append(pkgs, p)
+		}
+		sort.Strings(pkgs)
+
+		return pkgs, nil
+	default:
+		return nil, ErrPkgManagerUnsupported
+	}
+}
+
+// userBrew returns the taps, the formulae installed on request and the casks installed with brew
+func userBrew(bin string) (configuration.Brew, error) {
+	brew := configuration.Brew{Taps: []configuration.Tap{}, Formulas: []configuration.Package{}, Casks: []configuration.Package{}}
+
+	taps, err := commandLines(bin, "tap")
+	if err != nil {
+		return brew, err
+	}
+	for _, t := range taps {
+		// * older brew versions list the official taps
+		if t == "homebrew/core" || t == "homebrew/cask" {
+			continue
+		}
+		brew.Taps = append(brew.Taps, configuration.Tap{Name: t})
+	}
+
+	formulas, err := commandLines(bin, "leaves", "--installed-on-request")
+	if err != nil {
+		return brew, err
+	}
+	for _, f := range formulas {
+		brew.Formulas = append(brew.Formulas, configuration.Package{Name: f})
+	}
+
+	casks, err := commandLines(bin, "list", "--cask")
+	if err != nil {
+		return brew, err
+	}
+	for _, c := range casks {
+		brew.Casks = append(brew.Casks, configuration.Package{Name: c})
+	}
+
+	return brew, nil
+}
+
+// brewVersions parses "brew list --versions" output (E.g: "bat 0.22.1") for both formulae and casks
+func brewVersions(bin string) (map[string]string, error) {
+	versions := map[string]string{}
+	for _, kind := range []string{"--formula", "--cask"} {
+		out, err := exec.Command(bin, "list", "--versions", kind).Output()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fields := range outputFields(out) {
+			if len(fields) < 2 {
+				continue
+			}
+			versions[fields[0]] = fields[len(fields)-1]
+		}
+	}
+
+	return versions, nil
+}
+
+// dpkgVersions lists packages known by dpkg, used by both apt and nala
+func dpkgVersions() (map[string]string, error) {
+	out, err := exec.Command("dpkg-query", "-W", "-f=${Package} ${Version}\n").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, fields := range outputFields(out) {
+		if len(fields) != 2 {
+			continue
+		}
+		versions[fields[0]] = fields[1]
+	}
+
+	return versions, nil
+}
+
+// cargoVersions parses "cargo install --list" output (E.g: "ripgrep v13.0.0:")
+func cargoVersions(bin string) (map[string]string, error) {
+	out, err := exec.Command(bin, "install", "--list").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, fields := range outputFields(out) {
+		// * binaries provided by a crate are listed with an indentation and are ignored by outputFields
+		if len(fields) < 2 {
+			continue
+		}
+		versions[fields[0]] = strings.TrimSuffix(strings.TrimPrefix(fields[1], "v"), ":")
+	}
+
+	return versions, nil
+}
+
+// pipVersions parses "pip list --format=freeze" output (E.g: "requests==2.28.1")
+func pipVersions(bin string) (map[string]string, error) {
+	out, err := exec.Command(bin, "list", "--format=freeze").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, fields := range outputFields(out) {
+		pkg := strings.SplitN(fields[0], "==", 2)
+		if len(pkg) != 2 {
+			continue
+		}
+		// * pip package names are case insensitive
+		versions[strings.ToLower(pkg[0])] = pkg[1]
+	}
+
+	return versions, nil
+}
+
+// npmVersions lists globally installed npm packages
+func npmVersions(bin string) (map[string]string, error) {
+	out, err := exec.Command(bin, "ls", "--global", "--depth=0", "--json").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for name, dep := range list.Dependencies {
+		versions[name] = dep.Version
+	}
+
+	return versions, nil
+}
+
+// pipxVersions lists packages installed in pipx virtual environments
+func pipxVersions(bin string) (map[string]string, error) {
+	out, err := exec.Command(bin, "list", "--json").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var list struct {
+		Venvs map[string]struct {
+			Metadata struct {
+				MainPackage struct {
+					Package        string `json:"package"`
+					PackageVersion string `json:"package_version"`
+				} `json:"main_package"`
+			} `json:"metadata"`
+		} `json:"venvs"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for name, venv := range list.Venvs {
+		versions[strings.ToLower(name)] = venv.Metadata.MainPackage.PackageVersion
+	}
+
+	return versions, nil
+}
+
+// uvVersions parses "uv tool list" output (E.g: "ruff v0.1.0" followed by its executables "- ruff")
+func uvVersions(bin string) (map[string]string, error) {
+	out, err := exec.Command(bin, "tool", "list").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, fields := range outputFields(out) {
+		if len(fields) < 2 || fields[0] == "-" {
+			continue
+		}
+		versions[strings.ToLower(fields[0])] = strings.TrimPrefix(fields[1], "v")
+	}
+
+	return versions, nil
+}
+
+// pnpmVersions lists globally installed pnpm packages
+func pnpmVersions(bin string) (map[string]string, error) {
+	out, err := exec.Command(bin, "list", "--global", "--depth=0", "--json").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	for _, l := range list {
+		for name, dep := range l.Dependencies {
+			versions[name] = dep.Version
+		}
+	}
+
+	return versions, nil
+}
+
+// yarnVersions parses "yarn global list --json" output where each installed package
+// is reported as "\"name@version\" has binaries:"
+func yarnVersions(bin string) (map[string]string, error) {
+	out, err := exec.Command(bin, "global", "list", "--json").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		var line struct {
+			Type string `json:"type"`
+			Data string `json:"data"`
+		}
+		if err := json.Unmarshal(s.Bytes(), &line); err != nil || line.Type != "info" {
+			continue
+		}
+
+		m := yarnPackage.FindStringSubmatch(line.Data)
+		if m == nil {
+			continue
+		}
+		versions[m[1]] = m[2]
+	}
+
+	return versions, nil
+}
+
+// goVersions reads the build information of binaries installed with "go install".
+//
+// Packages are identified by their import path (E.g: "golang.org/x/tools/gopls").
+func goVersions(bin string) (map[string]string, error) {
+	dir, err := goBinDir(bin)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command(bin, "version", "-m", dir).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	var pkg string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "path":
+			pkg = fields[1]
+		case "mod":
+			if pkg != "" && len(fields) >= 3 {
+				versions[pkg] = fields[2]
+			}
+			pkg = ""
+		}
+	}
+
+	return versions, nil
+}
+
+// goBinDir returns the directory where "go install" writes binaries
+func goBinDir(bin string) (string, error) {
+	out, err := exec.Command(bin, "env", "GOBIN").Output()
+	if err != nil {
+		return "", err
+	}
+	if dir := strings.TrimSpace(string(out)); dir != "" {
+		return dir, nil
+	}
+
+	out, err = exec.Command(bin, "env", "GOPATH").Output()
+	if err != nil {
+		return "", err
+	}
+
+	// * GOPATH can contain multiple entries, binaries are installed in the first one
+	return path.Join(strings.Split(strings.TrimSpace(string(out)), ":")[0], "bin"), nil
+}
+
+// commandLines runs a command and returns its non-empty output lines
+func commandLines(name string, args ...string) ([]string, error) {
+	out, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{}
+	for _, fields := range outputFields(out) {
+		lines = append(lines, fields[0])
+	}
+
+	return lines, nil
+}
+
+// outputFields splits a command output into non-indented lines of fields
+func outputFields(out []byte) [][]string {
+	lines := [][]string{}
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		l := s.Text()
+		if l == "" || strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t") {
+			continue
+		}
+		lines = append(lines, strings.Fields(l))
+	}
+
+	return lines
+}
@@ -0,0 +1,105 @@
+package mapper
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// fakeCommand writes a shell script standing for a package manager and returns its path
+func fakeCommand(t *testing.T, script string) string {
+	t.Helper()
+
+	p := path.Join(t.TempDir(), "bin")
+	if err := os.WriteFile(p, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestInstalledVersions(t *testing.T) {
+	for _, tc := range []struct {
+		pkgManager string
+		script     string
+		want       map[string]string
+	}{
+		{
+			pkgManager: "brew",
+			script: `case "$*" in
+*--cask*) echo "temurin@17 17.0.8,7" ;;
+*) printf 'bat 0.22.1\npython@3.11 3.11.4_1 3.11.5\n' ;;
+esac
+`,
+			want: map[string]string{"bat": "0.22.1", "python@3.11": "3.11.5", "temurin@17": "17.0.8,7"},
+		},
+		{
+			pkgManager: "cargo",
+			script:     `printf 'ripgrep v13.0.0:\n    rg\ncargo-edit v0.11.9:\n    cargo-add\n    cargo-rm\n'`,
+			want:       map[string]string{"ripgrep": "13.0.0", "cargo-edit": "0.11.9"},
+		},
+		{
+			pkgManager: "pip",
+			script:     `printf 'Requests==2.28.1\n-e git+https://host/repo.git#egg=local\n'`,
+			want:       map[string]string{"requests": "2.28.1"},
+		},
+		{
+			pkgManager: "npm",
+			script:     `echo '{"dependencies":{"typescript":{"version":"5.0.4"},"@angular/cli":{"version":"15.0.0"}}}'`,
+			want:       map[string]string{"typescript": "5.0.4", "@angular/cli": "15.0.0"},
+		},
+		{
+			pkgManager: "pipx",
+			script:     `echo '{"venvs":{"Black":{"metadata":{"main_package":{"package":"black","package_version":"23.1.0"}}}}}'`,
+			want:       map[string]string{"black": "23.1.0"},
+		},
+		{
+			pkgManager: "uv",
+			script:     `printf 'Ruff v0.1.0\n- ruff\nhttpie v3.2.2\n- http\n- https\n'`,
+			want:       map[string]string{"ruff": "0.1.0", "httpie": "3.2.2"},
+		},
+		{
+			pkgManager: "pnpm",
+			script:     `echo '[{"dependencies":{"typescript":{"version":"5.0.4"}}}]'`,
+			want:       map[string]string{"typescript": "5.0.4"},
+		},
+		{
+			pkgManager: "yarn",
+			script: `cat <<'JSON'
+{"type":"info","data":"\"@vue/cli@5.0.8\" has binaries:"}
+{"type":"list","data":{"type":"bins-@vue/cli","items":["vue"]}}
+{"type":"info","data":"\"typescript@5.0.4\" has binaries:"}
+JSON
+`,
+			want: map[string]string{"@vue/cli": "5.0.8", "typescript": "5.0.4"},
+		},
+		{
+			pkgManager: "go",
+			script: `case "$*" in
+"env GOBIN") echo /tmp/gobin ;;
+*) printf '/tmp/gobin/gopls: go1.21.0\n\tpath\tgolang.org/x/tools/gopls\n\tmod\tgolang.org/x/tools/gopls\tv0.13.2\th1:abc=\n\tdep\tgolang.org/x/mod\tv0.12.0\th1:def=\n/tmp/gobin/local: go1.21.0\n\tpath\texample.com/local\n' ;;
+esac
+`,
+			want: map[string]string{"golang.org/x/tools/gopls": "v0.13.2"},
+		},
+	} {
+		t.Run(tc.pkgManager, func(t *testing.T) {
+			got, err := installedVersions(tc.pkgManager, fakeCommand(t, tc.script))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("installedVersions(%s) = %v, want %v", tc.pkgManager, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstalledVersionsErrors(t *testing.T) {
+	if _, err := installedVersions("cargo", fakeCommand(t, "exit 1\n")); err == nil {
+		t.Error("expected an error when the package manager fails")
+	}
+	if _, err := installedVersions("unknown", "unknown"); err != ErrPkgManagerUnsupported {
+		t.Errorf("got %v, want %v", err, ErrPkgManagerUnsupported)
+	}
+}
@@ -16,14 +16,16 @@ import (
 
 type Items struct {
 	locations  []configuration.OSLocation
+	blocks     []configuration.Block
 	storage    string
 	repository git.RepositoryActions
 	indexer    Indexer
 }
 
 type ItemsActions interface {
-	Action(action string)
+	Action(action string) []string
 	AddItems(items []configuration.OSLocation)
+	AddBlocks(blocks []configuration.Block)
 	CleanUp(removedLines []string) error
 }
 
@@ -34,6 +36,7 @@ func NewItemsActions(items []configuration.OSLocation, storage string, repositor
 
 	return &Items{
 		locations:  items,
+		blocks:     []configuration.Block{},
 		storage:    storage,
 		repository: repository,
 		indexer:    indexer,
@@ -42,14 +45,29 @@ func NewItemsActions(items []configuration.OSLocation, storage string, repositor
 
 // Action performs a "save" or "load" action on all given items.
 //
-// Any error is printed to STDERR and item is skipped.
+// Any error is printed to STDERR and item is skipped. Items hooks are run around the action,
+// a failing "pre-*" hook skips the item.
 //
 // If the performed action is "save", it'll also write the `.index` file with all new items.
-func (e *Items) Action(action string) {
+//
+// Returns the system files written by a "load" action.
+func (e *Items) Action(action string) []string {
 	log.Info("performing action", "action", action)
 	newLines := []string{}
+	changed := []string{}
+
+	// * blocks are processed as items, their location holds the path of the file sharing the block
+	locations := append([]configuration.OSLocation{}, e.locations...)
+	for _, b := range e.blocks {
+		locations = append(locations, b.OSLocation)
+	}
+
+	for i, l := range locations {
+		var block *configuration.Block
+		if i >= len(e.locations) {
+			block = &e.blocks[i-len(e.locations)]
+		}
 
-	for i, l := range e.locations {
 		storagePath, systemPath, err := misc.ConfigPaths(l, e.storage)
 		if err != nil {
 			log.Error("failed to resolve item paths", "item", i, "location", l, "err", err)
@@ -60,14 +78,65 @@ func (e *Items) Action(action string) {
 			continue
 		}
 
+		env := []string{
+			fmt.Sprintf("CONFIG_MAPPER_ACTION=%s", action),
+			fmt.Sprintf("CONFIG_MAPPER_SYSTEM_PATH=%s", systemPath),
+			fmt.Sprintf("CONFIG_MAPPER_STORAGE_PATH=%s", storagePath),
+		}
+
 		if action == "save" {
-			if newItem := e.saveItem(systemPath, storagePath, i); newItem != "" {
-				newLines = append(newLines, newItem)
+			if err := RunHooks(l.Hooks, configuration.HookPreSave, env...); err != nil {
+				log.Error("item skipped", "item", i, "location", l, "err", err)
+				continue
+			}
+
+			var newItem string
+			if block != nil {
+				newItem = e.saveBlock(systemPath, storagePath, *block)
+			} else if l.Merge != "" {
+				newItem = e.saveMerged(systemPath, storagePath, l)
 			} else {
+				newItem = e.saveItem(systemPath, storagePath, i)
+			}
+			if newItem == "" {
 				continue
 			}
+			newLines = append(newLines, newItem)
+
+			if err := RunHooks(l.Hooks, configuration.HookPostSave, env...); err != nil {
+				log.Error("item hook failed", "item", i, "location", l, "err", err)
+			}
 		} else {
-			e.loadItem(storagePath, systemPath, i)
+			if err := RunHooks(l.Hooks, configuration.HookPreLoad, env...); err != nil {
+				log.Error("item skipped", "item", i, "location", l, "err", err)
+				continue
+			}
+
+			var written []string
+			var ok bool
+			if block != nil {
+				written, ok = e.loadBlock(storagePath, systemPath, *block)
+			} else if l.Merge != "" {
+				written, ok = e.loadMerged(storagePath, systemPath, l)
+			} else {
+				written, ok = e.loadItem(storagePath, systemPath, i)
+			}
+			if !ok {
+				continue
+			}
+			changed = append(changed, written...)
+
+			if err := RunHooks(l.Hooks, configuration.HookPostLoad, env...); err != nil {
+				log.Error("item hook failed", "item", i, "location", l, "err", err)
+			}
+			if len(written) == 0 {
+				log.Info("item unchanged", "item", i, "location", l)
+			} else {
+				env = append(env, fmt.Sprintf("CONFIG_MAPPER_CHANGED_FILES=%s", strings.Join(written, "\n")))
+				if err := RunHooks(l.Hooks, configuration.HookOnChange, env...); err != nil {
+					log.Error("item hook failed", "item", i, "location", l, "err", err)
+				}
+			}
 		}
 
 		log.Info("item processed", "action", action, "item", i, "location", l)
@@ -78,6 +147,8 @@ func (e *Items) Action(action string) {
 			log.Fatal(err)
 		}
 	}
+
+	return changed
 }
 
 // saveItem saves a given item inside the configured saved location.
@@ -122,12 +193,12 @@ func (e *Items) saveItem(src, dst string, index int) string {
 				return ""
 			}
 		}
-		if err := misc.CopyFolder(src, dst, true); err != nil {
+		if _, err := misc.CopyFolder(src, dst, true); err != nil {
 			log.Error("failed to save folder from source to destination", "source", src, "destination", dst, "err", err)
 			return ""
 		}
 	} else {
-		if err := misc.CopyFile(src, dst); err != nil {
+		if _, err := misc.CopyFile(src, dst); err != nil {
 			log.Error("failed to save file from source to destination", "source", src, "destination", dst, "err", err)
 			return ""
 		}
@@ -144,18 +215,20 @@ func (e *Items) saveItem(src, dst string, index int) string {
 
 // loadItem loads a given item onto the system.
 //
-// If an error is given during the process, the function returns an empty string
-// (meaning the item hasn't been saved) and prints the error in STDERR.
-func (e *Items) loadItem(src, dst string, index int) {
+// Files already identical on the system aren't written. Returns the written files.
+//
+// If an error is given during the process, the function returns false
+// (meaning the item hasn't been loaded) and prints the error in STDERR.
+func (e *Items) loadItem(src, dst string, index int) ([]string, bool) {
 	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
 		log.Error("failed to create directory architecture for destination path", "path", path.Dir(dst), "err", err)
-		return
+		return nil, false
 	}
 
 	s, err := os.Stat(src)
 	if err != nil {
 		log.Error("failed to check if source path is a folder", "path", src, "err", err)
-		return
+		return nil, false
 	}
 
 	if s.IsDir() {
@@ -164,7 +237,7 @@ func (e *Items) loadItem(src, dst string, index int) {
 		if err != nil {
 			if !os.IsNotExist(err) {
 				log.Error("failed to check if destination folder exists", "path", dst, "err", err)
-				return
+				return nil, false
 			}
 		} else {
 			dstPerms = s.Mode()
@@ -173,25 +246,106 @@ func (e *Items) loadItem(src, dst string, index int) {
 		if err := os.Mkdir(dst, dstPerms); err != nil {
 			if !os.IsExist(err) {
 				log.Error("failed to create destination folder", "path", dst, "err", err)
-				return
+				return nil, false
 			}
 		}
-		if err := misc.CopyFolder(src, dst, false); err != nil {
+		written, err := misc.CopyFolder(src, dst, false)
+		if err != nil {
 			log.Error("failed to load folder from source to destination", "source", src, "destination", dst, "err", err)
-			return
+			return nil, false
 		}
-	} else {
-		if err := misc.CopyFile(src, dst); err != nil {
-			log.Error("failed to load file from source to destination", "source", src, "destination", dst, "err", err)
-			return
+
+		return written, true
+	}
+
+	written, err := misc.CopyFile(src, dst)
+	if err != nil {
+		log.Error("failed to load file from source to destination", "source", src, "destination", dst, "err", err)
+		return nil, false
+	}
+	if written {
+		return []string{dst}, true
+	}
+
+	return []string{}, true
+}
+
+// SystemPath maps a path relative to the saved location back to its system path through the configured items.
+//
+// Returns an empty string if the path doesn't belong to any item.
+func SystemPath(c configuration.Configuration, p string) string {
+	storage, err := misc.AbsolutePath(c.Storage.Path)
+	if err != nil {
+		return ""
+	}
+
+	locations := append(append([]configuration.OSLocation{}, c.Files...), c.Folders...)
+	for _, b := range c.Blocks {
+		locations = append(locations, b.OSLocation)
+	}
+
+	for _, l := range locations {
+		storagePath, systemPath, err := misc.ConfigPaths(l, storage)
+		if err != nil || storagePath == "" {
+			continue
+		}
+
+		rel := strings.TrimPrefix(storagePath, storage+"/")
+		if p == rel {
+			return systemPath
+		}
+		if strings.HasPrefix(p, rel+"/") {
+			return path.Join(systemPath, strings.TrimPrefix(p, rel+"/"))
+		}
+	}
+
+	return ""
+}
+
+// StoragePath maps a system path to its path relative to the saved location through the configured items.
+//
+// Returns an empty string if the path doesn't belong to any item.
+func StoragePath(c configuration.Configuration, p string) string {
+	storage, err := misc.AbsolutePath(c.Storage.Path)
+	if err != nil {
+		return ""
+	}
+	p, err = misc.AbsolutePath(p)
+	if err != nil {
+		return ""
+	}
+
+	locations := append(append([]configuration.OSLocation{}, c.Files...), c.Folders...)
+	for _, b := range c.Blocks {
+		locations = append(locations, b.OSLocation)
+	}
+
+	for _, l := range locations {
+		storagePath, systemPath, err := misc.ConfigPaths(l, storage)
+		if err != nil || storagePath == "" {
+			continue
+		}
+
+		rel := strings.TrimPrefix(storagePath, storage+"/")
+		if p == systemPath {
+			return rel
+		}
+		if strings.HasPrefix(p, systemPath+"/") {
+			return path.Join(rel, strings.TrimPrefix(p, systemPath+"/"))
 		}
 	}
+
+	return ""
 }
 
 func (e *Items) AddItems(items []configuration.OSLocation) {
 	e.locations = append(e.locations, items...)
 }
 
+func (e *Items) AddBlocks(blocks []configuration.Block) {
+	e.blocks = append(e.blocks, blocks...)
+}
+
 func (e *Items) CleanUp(removedLines []string) error {
 	for _, l := range removedLines {
 		path, err := misc.AbsolutePath(fmt.Sprintf("%s/%s", e.storage, l))
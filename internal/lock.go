@@ -0,0 +1,144 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v2"
+)
+
+const lockfileName = "packages.lock"
+
+var ErrNoLockfile = errors.New("no lockfile found in storage. Run \"config-mapper lock\" first")
+
+// Lockfile holds the exact version of declared packages by package manager
+type Lockfile map[string]map[string]string
+
+// ReadLockfile reads the lockfile stored at the root of the storage location
+func ReadLockfile(storage string) (Lockfile, error) {
+	p, err := misc.AbsolutePath(fmt.Sprintf("%s/%s", storage, lockfileName))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoLockfile
+		}
+		return nil, err
+	}
+
+	lock := Lockfile{}
+	if err := yaml.Unmarshal(b, &lock); err != nil {
+		return nil, err
+	}
+
+	return lock, nil
+}
+
+// Write writes the lockfile at the root of the storage location
+func (l Lockfile) Write(storage string) error {
+	p, err := misc.AbsolutePath(fmt.Sprintf("%s/%s", storage, lockfileName))
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p, append([]byte("# generated by \"config-mapper lock\". DO NOT EDIT\n"), b...), 0644)
+}
+
+// LockPackages records the installed version of every declared package by installation order
+func LockPackages(c configuration.PkgManagers) Lockfile {
+	lock := Lockfile{}
+
+	for _, pkgManager := range c.InstallationOrder {
+		// * runtimes aren't packages and nix versions are part of flake references
+		if pkgManager == toolchainsEntry || pkgManager == "nix" {
+			continue
+		}
+
+		declared, err := declaredPackages(c, pkgManager)
+		if err != nil {
+			log.Error(err, "package-manager", pkgManager)
+			continue
+		}
+		if len(declared) == 0 {
+			continue
+		}
+
+		bin, err := resolveBinary(pkgManager)
+		if err != nil {
+			log.Error(err, "package-manager", pkgManager)
+			continue
+		}
+
+		versions, err := installedVersions(pkgManager, bin)
+		if err != nil {
+			log.Error("failed to retrieve installed packages", "package-manager", pkgManager, "err", err)
+			continue
+		}
+
+		lock[pkgManager] = map[string]string{}
+		for _, p := range declared {
+			v, ok := lookupVersion(versions, pkgManager, p.Name)
+			if !ok {
+				log.Warn("package is not installed and won't be locked", "package-manager", pkgManager, "package", p.Name)
+				continue
+			}
+
+			lock[pkgManager][p.Name] = v
+		}
+
+		log.Info("packages locked", "package-manager", pkgManager, "count", len(lock[pkgManager]))
+	}
+
+	return lock
+}
+
+// apply returns the declared packages with their locked version.
+//
+// Packages missing from the lockfile keep their declared version and are reported in out.
+func (l Lockfile) apply(pkgManager string, declared []configuration.Package, out io.Writer) []configuration.Package {
+	// * brew only installs the latest version of a formula and nix versions are part of flake references
+	if pkgManager == "brew" || pkgManager == "nix" {
+		fmt.Fprintln(out, "package manager doesn't support version pinning, packages are installed unlocked")
+		return declared
+	}
+
+	locked := make([]configuration.Package, len(declared))
+	for i, p := range declared {
+		locked[i] = p
+
+		// * packages with custom arguments can't be pinned
+		if strings.Contains(p.Name, " ") {
+			continue
+		}
+
+		v, ok := l[pkgManager][p.Name]
+		if !ok {
+			fmt.Fprintf(out, "package %s not found in lockfile\n", p.Name)
+			continue
+		}
+
+		locked[i].Version = v
+	}
+
+	return locked
+}
+
+// lookupVersion returns the installed version of a package
+func lookupVersion(versions map[string]string, pkgManager, name string) (string, bool) {
+	v, ok := versions[normalizeName(pkgManager, name)]
+	return v, ok
+}
@@ -0,0 +1,77 @@
+package mapper
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+)
+
+const logsTailLines = 20
+
+// runLogs is the directory holding package managers output of a "load --pkgs" run
+type runLogs struct {
+	dir string
+}
+
+// newRunLogs creates a new run directory in "$XDG_STATE_HOME/config-mapper/logs"
+// and removes the oldest runs to only keep the last ones
+func newRunLogs(keep int) (*runLogs, error) {
+	root, err := misc.StatePath("logs")
+	if err != nil {
+		return nil, err
+	}
+
+	dir := path.Join(root, time.Now().Format("20060102-150405.000"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	runs, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// * run directories are named after their start date, the oldest come first
+	names := []string{}
+	for _, r := range runs {
+		if r.IsDir() {
+			names = append(names, r.Name())
+		}
+	}
+	sort.Strings(names)
+
+	if keep > 0 && len(names) > keep {
+		for _, n := range names[:len(names)-keep] {
+			if err := os.RemoveAll(path.Join(root, n)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &runLogs{dir: dir}, nil
+}
+
+// create creates the log file of a package manager
+func (l *runLogs) create(pkgManager string) (*os.File, error) {
+	return os.Create(path.Join(l.dir, fmt.Sprintf("%s.log", pkgManager)))
+}
+
+// tailFile returns the last n lines of a file
+func tailFile(p string, n int) (string, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
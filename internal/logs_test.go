@@ -0,0 +1,84 @@
+package mapper
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewRunLogs(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("XDG_STATE_HOME", state)
+
+	root := path.Join(state, "config-mapper", "logs")
+	for _, old := range []string{"20230101-100000.000", "20230102-100000.000", "20230103-100000.000"} {
+		if err := os.MkdirAll(path.Join(root, old), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logs, err := newRunLogs(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path.Dir(logs.dir) != root {
+		t.Errorf("run directory = %s, want a directory of %s", logs.dir, root)
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(got)
+	if want := []string{"20230103-100000.000", path.Base(logs.dir)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept runs = %v, want %v", got, want)
+	}
+
+	f, err := logs.create("cargo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Name() != path.Join(logs.dir, "cargo.log") {
+		t.Errorf("log file = %s, want %s", f.Name(), path.Join(logs.dir, "cargo.log"))
+	}
+}
+
+func TestTailFile(t *testing.T) {
+	p := path.Join(t.TempDir(), "cargo.log")
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		n       int
+		want    string
+	}{
+		{"shorter than n", "a\nb\n", 3, "a\nb"},
+		{"last lines", "a\nb\nc\nd\n", 2, "c\nd"},
+		{"no trailing newline", "a\nb\nc", 1, "c"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := os.WriteFile(p, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tailFile(p, tc.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("tailFile(%d) = %q, want %q", tc.n, got, tc.want)
+			}
+		})
+	}
+
+	if _, err := tailFile(strings.TrimSuffix(p, ".log")+".missing", 1); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,382 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/charmbracelet/log"
+	"github.com/pelletier/go-toml"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var (
+	ErrMergeFormat = errors.New("merge format must be one of json, yaml or toml")
+	ErrMergeFolder = errors.New("only files can be merged")
+)
+
+// document is a decoded JSON, YAML or TOML file
+type document map[string]interface{}
+
+// decodeDocument decodes a file content. JSON files may contain comments and trailing commas (E.g: VS Code settings).
+func decodeDocument(format string, b []byte) (document, error) {
+	doc := document{}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return doc, nil
+	}
+
+	switch format {
+	case "json":
+		// * numbers are kept as written, float64 would turn large integers into exponents
+		dec := json.NewDecoder(bytes.NewReader(stripJSONComments(b)))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+	case "yaml":
+		// * YAML 1.2 keeps keys like "y" or "on" as strings in applications configuration
+		var raw interface{}
+		if err := yamlv3.Unmarshal(b, &raw); err != nil {
+			return nil, err
+		}
+		m, ok := normalizeYAML(raw).(map[string]interface{})
+		if !ok {
+			return nil, errors.New("yaml document isn't a dictionary")
+		}
+		doc = m
+	case "toml":
+		t, err := toml.LoadBytes(b)
+		if err != nil {
+			return nil, err
+		}
+		doc = t.ToMap()
+	default:
+		return nil, ErrMergeFormat
+	}
+
+	return doc, nil
+}
+
+// encodeDocument encodes a document. JSON files keep the indentation of the original file if any.
+func encodeDocument(format string, doc document, original []byte) ([]byte, error) {
+	switch format {
+	case "json":
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", jsonIndent(original))
+		if err := enc.Encode(map[string]interface{}(doc)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "yaml":
+		var buf bytes.Buffer
+		enc := yamlv3.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(map[string]interface{}(doc)); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), enc.Close()
+	case "toml":
+		t, err := toml.TreeFromMap(doc)
+		if err != nil {
+			return nil, err
+		}
+		s, err := t.ToTomlString()
+		return []byte(s), err
+	default:
+		return nil, ErrMergeFormat
+	}
+}
+
+// normalizeYAML converts YAML dictionaries into string keyed maps, at any depth
+func normalizeYAML(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range t {
+			m[fmt.Sprint(k)] = normalizeYAML(v)
+		}
+		return m
+	case map[string]interface{}:
+		for k, v := range t {
+			t[k] = normalizeYAML(v)
+		}
+		return t
+	case []map[string]interface{}:
+		l := make([]interface{}, len(t))
+		for i := range t {
+			l[i] = normalizeYAML(t[i])
+		}
+		return l
+	case []interface{}:
+		for i := range t {
+			t[i] = normalizeYAML(t[i])
+		}
+		return t
+	default:
+		return v
+	}
+}
+
+// jsonIndent returns the indentation of the first indented line (DEFAULT: 2 spaces)
+func jsonIndent(b []byte) string {
+	for _, l := range strings.Split(string(b), "\n") {
+		if trimmed := strings.TrimLeft(l, " \t"); trimmed != "" && len(trimmed) < len(l) {
+			return l[:len(l)-len(trimmed)]
+		}
+	}
+
+	return "  "
+}
+
+// stripJSONComments removes "//" and "/* */" comments and trailing commas outside of strings
+func stripJSONComments(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	inString := false
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(b) {
+				i++
+				out = append(out, b[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			if i < len(b) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(b) && b[i+1] == '*':
+			i += 2
+			for i+1 < len(b) && !(b[i] == '*' && b[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			// * drop a trailing comma before the closing character
+			trimmed := bytes.TrimRight(out, " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out = append(trimmed[:len(trimmed)-1], out[len(trimmed):]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// mergeDocuments deep-merges src into dst. Values from src win, dictionaries are merged recursively.
+func mergeDocuments(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, srcOk := v.(map[string]interface{})
+		dstMap, dstOk := dst[k].(map[string]interface{})
+		if srcOk && dstOk {
+			mergeDocuments(dstMap, srcMap)
+			continue
+		}
+
+		dst[k] = v
+	}
+}
+
+// copyValue deep-copies dictionaries and lists of a decoded document
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = copyValue(v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			l[i] = copyValue(v)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
+// filterDocument keeps the allowed key paths (all if empty) and removes the denied ones.
+//
+// A key path matches a literal key first (E.g: "editor.fontSize" in VS Code settings),
+// then a dotted path through nested dictionaries (E.g: "font.normal.family").
+func filterDocument(doc document, allow, deny []string) document {
+	filtered := doc
+	if len(allow) > 0 {
+		filtered = document{}
+		for _, p := range allow {
+			copyKeyPath(doc, filtered, p)
+		}
+	}
+
+	for _, p := range deny {
+		deleteKeyPath(filtered, p)
+	}
+
+	return filtered
+}
+
+func copyKeyPath(src, dst map[string]interface{}, p string) {
+	if v, ok := src[p]; ok {
+		dst[p] = v
+		return
+	}
+
+	for k, v := range src {
+		m, ok := v.(map[string]interface{})
+		if !ok || !strings.HasPrefix(p, k+".") {
+			continue
+		}
+
+		sub, ok := dst[k].(map[string]interface{})
+		if !ok {
+			sub = map[string]interface{}{}
+			dst[k] = sub
+		}
+		copyKeyPath(m, sub, strings.TrimPrefix(p, k+"."))
+		if len(sub) == 0 {
+			delete(dst, k)
+		}
+	}
+}
+
+func deleteKeyPath(doc map[string]interface{}, p string) {
+	if _, ok := doc[p]; ok {
+		delete(doc, p)
+		return
+	}
+
+	for k, v := range doc {
+		if m, ok := v.(map[string]interface{}); ok && strings.HasPrefix(p, k+".") {
+			deleteKeyPath(m, strings.TrimPrefix(p, k+"."))
+		}
+	}
+}
+
+func readDocument(format, p string) (document, []byte, error) {
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := decodeDocument(format, b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode %s: %v", p, err)
+	}
+
+	return doc, b, nil
+}
+
+// saveMerged saves the allowed keys of a system file inside the configured saved location.
+//
+// Returns the relative item location from the saved location to write the index, or an empty string
+// if the item hasn't been saved.
+func (e *Items) saveMerged(src, dst string, l configuration.OSLocation) string {
+	if s, err := os.Stat(src); err == nil && s.IsDir() {
+		log.Error("failed to save merged item", "path", src, "err", ErrMergeFolder)
+		return ""
+	}
+
+	doc, _, err := readDocument(l.Merge, src)
+	if err != nil {
+		log.Error("failed to read merged item", "path", src, "err", err)
+		return ""
+	}
+
+	original, _ := os.ReadFile(dst)
+	b, err := encodeDocument(l.Merge, filterDocument(doc, l.Keys, l.ExcludeKeys), original)
+	if err != nil {
+		log.Error("failed to encode merged item", "path", src, "err", err)
+		return ""
+	}
+
+	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+		log.Error("failed to create directory architecture for destination path", "path", path.Dir(dst), "err", err)
+		return ""
+	}
+	if err := os.WriteFile(dst, b, 0644); err != nil {
+		log.Error("failed to save merged item", "destination", dst, "err", err)
+		return ""
+	}
+
+	p, err := misc.AbsolutePath(e.storage)
+	if err != nil {
+		log.Error("failed resolve absolute path from configuration storage", "err", err)
+		return ""
+	}
+
+	return strings.ReplaceAll(dst, p+"/", "")
+}
+
+// loadMerged deep-merges a saved file into the system file, keeping keys not managed by config-mapper.
+//
+// The system file is only written if its content changed.
+func (e *Items) loadMerged(src, dst string, l configuration.OSLocation) ([]string, bool) {
+	saved, _, err := readDocument(l.Merge, src)
+	if err != nil {
+		log.Error("failed to read saved merged item", "path", src, "err", err)
+		return nil, false
+	}
+	saved = filterDocument(saved, nil, l.ExcludeKeys)
+
+	perms := os.FileMode(0644)
+	current, original, err := readDocument(l.Merge, dst)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error("failed to read merged item", "path", dst, "err", err)
+			return nil, false
+		}
+		current = document{}
+	} else if s, err := os.Stat(dst); err == nil {
+		perms = s.Mode()
+	}
+
+	// * the current document is kept as is to compare it with the merged one
+	merged := copyValue(map[string]interface{}(current)).(map[string]interface{})
+	mergeDocuments(merged, saved)
+
+	if reflect.DeepEqual(merged, map[string]interface{}(current)) {
+		return []string{}, true
+	}
+
+	b, err := encodeDocument(l.Merge, document(merged), original)
+	if err != nil {
+		log.Error("failed to encode merged item", "path", dst, "err", err)
+		return nil, false
+	}
+
+	if err := os.MkdirAll(path.Dir(dst), 0755); err != nil {
+		log.Error("failed to create directory architecture for destination path", "path", path.Dir(dst), "err", err)
+		return nil, false
+	}
+	if err := os.WriteFile(dst, b, perms); err != nil {
+		log.Error("failed to write merged item", "path", dst, "err", err)
+		return nil, false
+	}
+
+	return []string{dst}, true
+}
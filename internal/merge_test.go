@@ -0,0 +1,168 @@
+package mapper
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStripJSONComments(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no comments", `{"a": 1}`, `{"a": 1}`},
+		{"line comment", "{\n  // comment\n  \"a\": 1\n}", "{\n  \n  \"a\": 1\n}"},
+		{"block comment", `{/* comment */"a": 1}`, `{"a": 1}`},
+		{"comments in strings", `{"url": "https://host/*path*/"}`, `{"url": "https://host/*path*/"}`},
+		{"escaped quote", `{"a": "\"// not a comment"}`, `{"a": "\"// not a comment"}`},
+		{"trailing commas", "{\"a\": [1, 2,],\n}", "{\"a\": [1, 2]\n}"},
+		{"trailing comma before comment", "{\"a\": 1, // comment\n}", "{\"a\": 1 \n}"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(stripJSONComments([]byte(tc.in))); got != tc.want {
+				t.Errorf("stripJSONComments(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMergeDocuments(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		dst, src map[string]interface{}
+		want     map[string]interface{}
+	}{
+		{
+			name: "source wins",
+			dst:  map[string]interface{}{"a": 1, "b": 2},
+			src:  map[string]interface{}{"b": 3, "c": 4},
+			want: map[string]interface{}{"a": 1, "b": 3, "c": 4},
+		},
+		{
+			name: "nested dictionaries are merged",
+			dst:  map[string]interface{}{"font": map[string]interface{}{"size": 12, "family": "mono"}},
+			src:  map[string]interface{}{"font": map[string]interface{}{"size": 14}},
+			want: map[string]interface{}{"font": map[string]interface{}{"size": 14, "family": "mono"}},
+		},
+		{
+			name: "lists are replaced",
+			dst:  map[string]interface{}{"l": []interface{}{1, 2}},
+			src:  map[string]interface{}{"l": []interface{}{3}},
+			want: map[string]interface{}{"l": []interface{}{3}},
+		},
+		{
+			name: "dictionary replaces a value",
+			dst:  map[string]interface{}{"a": "string"},
+			src:  map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+			want: map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mergeDocuments(tc.dst, tc.src)
+			if !reflect.DeepEqual(tc.dst, tc.want) {
+				t.Errorf("mergeDocuments() = %v, want %v", tc.dst, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterDocument(t *testing.T) {
+	doc := func() document {
+		return document{
+			"editor.fontSize": 14,
+			"font": map[string]interface{}{
+				"normal": map[string]interface{}{"family": "mono", "style": "regular"},
+				"size":   12,
+			},
+			"token": "secret",
+		}
+	}
+
+	for _, tc := range []struct {
+		name        string
+		allow, deny []string
+		want        document
+	}{
+		{
+			name: "no filters",
+			want: doc(),
+		},
+		{
+			name:  "literal key",
+			allow: []string{"editor.fontSize"},
+			want:  document{"editor.fontSize": 14},
+		},
+		{
+			name:  "dotted path",
+			allow: []string{"font.normal.family"},
+			want:  document{"font": map[string]interface{}{"normal": map[string]interface{}{"family": "mono"}}},
+		},
+		{
+			name: "denied keys",
+			deny: []string{"token", "font.normal.style"},
+			want: document{
+				"editor.fontSize": 14,
+				"font": map[string]interface{}{
+					"normal": map[string]interface{}{"family": "mono"},
+					"size":   12,
+				},
+			},
+		},
+		{
+			name:  "allowed then denied",
+			allow: []string{"font"},
+			deny:  []string{"font.size"},
+			want:  document{"font": map[string]interface{}{"normal": map[string]interface{}{"family": "mono", "style": "regular"}}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := filterDocument(doc(), tc.allow, tc.deny); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("filterDocument() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDocument(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		format string
+		in     string
+		want   document
+	}{
+		{
+			name:   "json numbers are kept as written",
+			format: "json",
+			in: `{"id": 12345678901234567890, "ratio": 1.5, // comment
+}`,
+			want: document{"id": json.Number("12345678901234567890"), "ratio": json.Number("1.5")},
+		},
+		{
+			name:   "nested yaml dictionaries have string keys",
+			format: "yaml",
+			in:     "a:\n  1: one\n  l:\n    - {2: two}\n",
+			want: document{"a": map[string]interface{}{
+				"1": "one",
+				"l": []interface{}{map[string]interface{}{"2": "two"}},
+			}},
+		},
+		{
+			name:   "empty",
+			format: "toml",
+			in:     "\n",
+			want:   document{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodeDocument(tc.format, []byte(tc.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("decodeDocument() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
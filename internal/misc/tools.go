@@ -1,10 +1,14 @@
 package misc
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"os/exec"
 	"path"
 	"runtime"
 	"strings"
@@ -42,6 +46,85 @@ func AbsolutePath(p string) (string, error) {
 	return path.Clean(finalPath), nil
 }
 
+// StatePath returns the location of a file holding config-mapper state for the current system.
+//
+// The state directory is "$XDG_STATE_HOME/config-mapper" (DEFAULT: ~/.local/state/config-mapper)
+// and is created if it doesn't exist.
+func StatePath(name string) (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		h, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		dir = path.Join(h, ".local", "state")
+	}
+
+	dir = path.Join(dir, "config-mapper")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	return path.Join(dir, name), nil
+}
+
+// Confirm asks a yes/no question on STDOUT and reads the answer from STDIN.
+//
+// Any answer other than "y" or "yes" is considered as a refusal.
+func Confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// WriteFileSudo writes data into a file, creating its parent directories if needed.
+//
+// If the current user isn't allowed to write the file, it's written through "sudo".
+func WriteFileSudo(p string, data []byte, perm fs.FileMode) error {
+	if err := os.MkdirAll(path.Dir(p), 0755); err == nil {
+		if err := os.WriteFile(p, data, perm); err == nil || !errors.Is(err, fs.ErrPermission) {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+
+	if err := exec.Command("sudo", "mkdir", "-p", path.Dir(p)).Run(); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", path.Dir(p), err)
+	}
+
+	cmd := exec.Command("sudo", "tee", p)
+	cmd.Stdin = bytes.NewReader(data)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to write file %s: %v", p, err)
+	}
+
+	return exec.Command("sudo", "chmod", fmt.Sprintf("%o", perm), p).Run()
+}
+
+// RemoveFileSudo removes a file, with sudo if the permission is denied. A missing file isn't an error.
+func RemoveFileSudo(p string) error {
+	err := os.Remove(p)
+	if err == nil || os.IsNotExist(err) {
+		return nil
+	}
+	if !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+
+	if err := exec.Command("sudo", "rm", "-f", p).Run(); err != nil {
+		return fmt.Errorf("failed to remove file %s: %v", p, err)
+	}
+	return nil
+}
+
 func getPaths(p string, l string) (string, string, error) {
 	paths := strings.Split(p, ":")
 
@@ -62,33 +145,87 @@ func getPaths(p string, l string) (string, string, error) {
 	return src, dst, nil
 }
 
-func CopyFile(src, dst string) error {
+// CopyFile copies a file from src to dst with its permissions.
+//
+// The file isn't written if dst already has the same content and permissions.
+// Returns true if dst was written.
+func CopyFile(src, dst string) (bool, error) {
 	s, err := os.Stat(src)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	if d, err := os.Stat(dst); err == nil && d.Size() == s.Size() {
+		same, err := sameContent(src, dst)
+		if err != nil {
+			return false, err
+		}
+		if same {
+			if d.Mode() == s.Mode() {
+				return false, nil
+			}
+			return true, os.Chmod(dst, s.Mode())
+		}
 	}
 
 	in, err := os.Open(src)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer in.Close()
 
 	out, err := os.Create(dst)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer out.Close()
 
 	if _, err := io.Copy(out, in); err != nil {
-		return err
+		return false, err
 	}
 
 	if err := os.Chmod(dst, s.Mode()); err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return true, nil
+}
+
+// sameContent compares the content of two files of the same size
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 32*1024)
+	bufB := make([]byte, 32*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		doneA := errors.Is(errA, io.EOF) || errors.Is(errA, io.ErrUnexpectedEOF)
+		doneB := errors.Is(errB, io.EOF) || errors.Is(errB, io.ErrUnexpectedEOF)
+		if errA != nil && !doneA {
+			return false, errA
+		}
+		if errB != nil && !doneB {
+			return false, errB
+		}
+		if doneA || doneB {
+			return doneA && doneB, nil
+		}
+	}
 }
 
 func ConfigPaths(os configuration.OSLocation, location string) (string, string, error) {
@@ -121,17 +258,20 @@ func ConfigPaths(os configuration.OSLocation, location string) (string, string,
 
 var ignored map[string]bool
 
-func CopyFolder(src, dst string, checkIgnore bool) error {
+// CopyFolder copies the content of src into dst.
+//
+// Returns the dst paths of written files, files with the same content and permissions are skipped.
+func CopyFolder(src, dst string, checkIgnore bool) ([]string, error) {
 	items, err := os.ReadDir(src)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if checkIgnore {
 		f, err := os.ReadFile(fmt.Sprintf("%s/.ignore", src))
 		if err != nil && !errors.Is(err, io.EOF) {
 			if !errors.Is(err, os.ErrNotExist) {
-				return err
+				return nil, err
 			}
 		}
 
@@ -143,6 +283,7 @@ func CopyFolder(src, dst string, checkIgnore bool) error {
 		}
 	}
 
+	written := []string{}
 	for _, i := range items {
 		itemName := i.Name()
 		srcItem := fmt.Sprintf("%s/%s", src, itemName)
@@ -158,23 +299,29 @@ func CopyFolder(src, dst string, checkIgnore bool) error {
 		if i.IsDir() {
 			info, err := i.Info()
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			if err := os.MkdirAll(dstItem, info.Mode()); err != nil {
-				return err
+				return nil, err
 			}
-			if err := CopyFolder(srcItem, dstItem, false); err != nil {
-				return err
+			w, err := CopyFolder(srcItem, dstItem, false)
+			if err != nil {
+				return nil, err
 			}
+			written = append(written, w...)
 
 			continue
 		}
 
-		if err := CopyFile(srcItem, dstItem); err != nil {
-			return err
+		w, err := CopyFile(srcItem, dstItem)
+		if err != nil {
+			return nil, err
+		}
+		if w {
+			written = append(written, dstItem)
 		}
 	}
 
-	return nil
+	return written, nil
 }
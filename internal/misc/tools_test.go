@@ -0,0 +1,85 @@
+package misc
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := path.Join(dir, "src"), path.Join(dir, "dst")
+
+	for _, tc := range []struct {
+		name    string
+		content string
+		perm    os.FileMode
+		want    bool
+	}{
+		{"new file", "a", 0644, true},
+		{"same file", "a", 0644, false},
+		{"changed content", "b", 0644, true},
+		{"changed permissions", "b", 0600, true},
+		{"same size", "c", 0600, true},
+	} {
+		if err := os.WriteFile(src, []byte(tc.content), tc.perm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(src, tc.perm); err != nil {
+			t.Fatal(err)
+		}
+
+		written, err := CopyFile(src, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if written != tc.want {
+			t.Errorf("%s: CopyFile() = %t, want %t", tc.name, written, tc.want)
+		}
+
+		info, err := os.Stat(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := os.ReadFile(dst); string(got) != tc.content || info.Mode().Perm() != tc.perm {
+			t.Errorf("%s: dst = %q (%s), want %q (%s)", tc.name, got, info.Mode().Perm(), tc.content, tc.perm)
+		}
+	}
+}
+
+func TestCopyFolder(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := path.Join(dir, "src"), path.Join(dir, "dst")
+	for name, content := range map[string]string{"init.lua": "a", "lua/plugins.lua": "b"} {
+		p := path.Join(src, name)
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := CopyFolder(src, dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{path.Join(dst, "init.lua"), path.Join(dst, "lua", "plugins.lua")}; !reflect.DeepEqual(written, want) {
+		t.Errorf("CopyFolder() = %v, want %v", written, want)
+	}
+
+	if err := os.WriteFile(path.Join(src, "init.lua"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	written, err = CopyFolder(src, dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{path.Join(dst, "init.lua")}; !reflect.DeepEqual(written, want) {
+		t.Errorf("CopyFolder() of an unchanged folder = %v, want %v", written, want)
+	}
+}
@@ -0,0 +1,143 @@
+package mapper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/charmbracelet/log"
+)
+
+// nixFeatures enables flakes and "nix profile" on installations where they're still experimental
+var nixFeatures = []string{"--extra-experimental-features", "nix-command flakes"}
+
+var nixSafePath = regexp.MustCompile(`^[A-Za-z0-9._/+-]+$`)
+
+type nixElement struct {
+	OriginalURL string `json:"originalUrl"`
+	AttrPath    string `json:"attrPath"`
+}
+
+// nixVersions lists flake references installed in the user profile.
+//
+// Versions aren't reported by "nix profile list", references are normalized with normalizeNixRef.
+func nixVersions(bin string) (map[string]string, error) {
+	out, err := exec.Command(bin, append(nixFeatures, "profile", "list", "--json")...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var profile struct {
+		Elements json.RawMessage `json:"elements"`
+	}
+	if err := json.Unmarshal(out, &profile); err != nil {
+		return nil, err
+	}
+
+	// * elements are a list in older nix versions and a map indexed by name in newer ones
+	elements := []nixElement{}
+	if bytes.HasPrefix(bytes.TrimSpace(profile.Elements), []byte("[")) {
+		if err := json.Unmarshal(profile.Elements, &elements); err != nil {
+			return nil, err
+		}
+	} else {
+		named := map[string]nixElement{}
+		if err := json.Unmarshal(profile.Elements, &named); err != nil {
+			return nil, err
+		}
+		for _, e := range named {
+			elements = append(elements, e)
+		}
+	}
+
+	versions := map[string]string{}
+	for _, e := range elements {
+		if e.OriginalURL == "" {
+			continue
+		}
+		versions[normalizeNixRef(fmt.Sprintf("%s#%s", e.OriginalURL, e.AttrPath))] = ""
+	}
+
+	return versions, nil
+}
+
+// normalizeNixRef returns a comparable flake reference: "flake:nixpkgs#legacyPackages.x86_64-linux.ripgrep"
+// and "nixpkgs#ripgrep" are both normalized into "nixpkgs#ripgrep"
+func normalizeNixRef(ref string) string {
+	url, attr := ref, "default"
+	if i := strings.Index(ref, "#"); i >= 0 {
+		url, attr = ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(attr, "."); i >= 0 {
+		attr = attr[i+1:]
+	}
+
+	return fmt.Sprintf("%s#%s", strings.TrimPrefix(url, "flake:"), attr)
+}
+
+// buildNixCommand returns the command installing flake references missing from the user profile
+func buildNixCommand(bin string, declared []configuration.Package, out io.Writer) []*installCommand {
+	installed, err := nixVersions(bin)
+	if err != nil {
+		fmt.Fprintf(out, "failed to list nix profile, all packages will be installed: %v\n", err)
+		installed = map[string]string{}
+	}
+
+	missing := []configuration.Package{}
+	for _, p := range declared {
+		if _, ok := installed[normalizeNixRef(p.Name)]; ok {
+			continue
+		}
+		missing = append(missing, p)
+	}
+	if len(missing) == 0 {
+		return []*installCommand{}
+	}
+
+	return []*installCommand{buildDefaultCommand("nix", append(append([]string{bin}, nixFeatures...), "profile", "install"), missing, out)}
+}
+
+// NixHomeFragment renders a home-manager module linking configured files from the storage location.
+//
+// Only files located in the home directory are supported by home-manager, others are skipped.
+func NixHomeFragment(files []configuration.OSLocation, storage string) ([]byte, error) {
+	home, err := misc.AbsolutePath("~")
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "# generated by \"config-mapper nix-home\". DO NOT EDIT")
+	fmt.Fprintln(&b, "{ ... }:")
+	fmt.Fprintln(&b, "{")
+	for i, f := range files {
+		storagePath, systemPath, err := misc.ConfigPaths(f, storage)
+		if err != nil {
+			log.Error("failed to resolve item paths", "item", i, "location", f, "err", err)
+			continue
+		}
+		if storagePath == "" && systemPath == "" {
+			continue
+		}
+		if !strings.HasPrefix(systemPath, home+"/") {
+			log.Warn("file is outside of the home directory and is skipped", "item", i, "path", systemPath)
+			continue
+		}
+
+		source := storagePath
+		if !nixSafePath.MatchString(source) {
+			source = fmt.Sprintf("/. + %q", storagePath)
+		}
+
+		fmt.Fprintf(&b, "  home.file.%q.source = %s;\n", strings.TrimPrefix(systemPath, home+"/"), source)
+	}
+	fmt.Fprintln(&b, "}")
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,58 @@
+package mapper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNixVersions(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		output string
+		want   map[string]string
+	}{
+		{
+			name:   "elements list",
+			output: `{"elements":[{"originalUrl":"flake:nixpkgs","attrPath":"legacyPackages.x86_64-linux.ripgrep"},{"originalUrl":"","attrPath":""}],"version":2}`,
+			want:   map[string]string{"nixpkgs#ripgrep": ""},
+		},
+		{
+			name:   "elements map",
+			output: `{"elements":{"ripgrep":{"originalUrl":"flake:nixpkgs","attrPath":"legacyPackages.aarch64-darwin.ripgrep"},"tool":{"originalUrl":"github:owner/repo","attrPath":"packages.x86_64-linux.default"}},"version":3}`,
+			want:   map[string]string{"nixpkgs#ripgrep": "", "github:owner/repo#default": ""},
+		},
+		{
+			name:   "empty profile",
+			output: `{"elements":{},"version":3}`,
+			want:   map[string]string{},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := nixVersions(fakeCommand(t, "echo '"+tc.output+"'\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("nixVersions() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeNixRef(t *testing.T) {
+	for _, tc := range []struct {
+		ref  string
+		want string
+	}{
+		{"nixpkgs#ripgrep", "nixpkgs#ripgrep"},
+		{"flake:nixpkgs#legacyPackages.x86_64-linux.ripgrep", "nixpkgs#ripgrep"},
+		{"github:owner/repo", "github:owner/repo#default"},
+		{"git+ssh://git@host/repo#tool", "git+ssh://git@host/repo#tool"},
+	} {
+		t.Run(tc.ref, func(t *testing.T) {
+			if got := normalizeNixRef(tc.ref); got != tc.want {
+				t.Errorf("normalizeNixRef(%q) = %q, want %q", tc.ref, got, tc.want)
+			}
+		})
+	}
+}
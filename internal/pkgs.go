@@ -1,143 +1,753 @@
 package mapper
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
 	"runtime"
 	"strings"
+	"sync"
 
 	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
 	"github.com/charmbracelet/log"
-	"github.com/gernest/wow"
-	"github.com/gernest/wow/spin"
 	"github.com/spf13/viper"
 )
 
-// InstallPackages install all packages from the configuration file by installation order
-func InstallPackages(c configuration.PkgManagers) error {
+var (
+	ErrPkgManagerUnsupported  = errors.New("package manager not supported")
+	ErrPkgManagerNotAvailable = errors.New("package manager not available on your system")
+	ErrPipNotAvailable        = errors.New("pip and pip3 are not available on your system")
+	ErrExternallyManaged      = errors.New("python environment is externally managed and pipx is not available on your system")
+	ErrDependencyCycle        = errors.New("package managers dependency cycle")
+)
+
+// InstallPackages install all packages from the configuration file.
+//
+// Package managers declared in "depends-on" run as soon as their dependencies are installed,
+// concurrently with other package managers. Others wait for all previous package managers
+// in the installation order.
+//
+// When a lockfile is given, packages are installed with their locked version if the package manager supports it.
+//
+// Failures don't stop other installations unless "--fail-fast" is set. An InstallError reporting
+// all failures is returned if any package failed to install.
+//
+// Package managers with "on-change" hooks, or all of them if trackChanges is set, compare their installed
+// packages before and after the installation. Returns true if any of them changed.
+func InstallPackages(c configuration.PkgManagers, lock Lockfile, trackChanges bool) (bool, error) {
 	pkgManagers := map[string]bool{}
 	for _, pkgManager := range viper.GetStringSlice("exclude-pkg-managers") {
 		pkgManagers[pkgManager] = true
 	}
 
+	entries := []string{}
 	for _, pkgManager := range c.InstallationOrder {
-		log.Info("installing packages", "package-manager", pkgManager)
 		if _, ok := pkgManagers[pkgManager]; ok {
 			log.Info("skipping package manager", "package-manager", pkgManager)
 			continue
 		}
+		entries = append(entries, pkgManager)
+	}
 
-		var pkgs []string
-		switch pkgManager {
-		case "brew":
-			pkgs = c.Brew
-		case "apt":
-			pkgs = c.Apt
-		case "cargo":
-			pkgs = c.Cargo
-		case "npm":
-			pkgs = c.Npm
-		case "pip":
-			pkgs = c.Pip
-		case "go":
-			pkgs = c.Go
-		case "nala":
-			pkgs = c.Nala
-		default:
-			log.Error("package manager not supported", "package-manager", pkgManager)
-			continue
+	deps, err := resolveDependencies(entries, c.DependsOn)
+	if err != nil {
+		return false, err
+	}
+
+	state, err := readInstallState()
+	if err != nil {
+		log.Error("failed to read installed packages state, installed packages won't be recorded", "err", err)
+	}
+
+	// * ask for the sudo password before the progress display takes over the terminal
+	if needsSudo(c, entries) {
+		if err := sudoValidate(); err != nil {
+			return false, err
 		}
+	}
+
+	i := &installer{
+		config:          c,
+		lock:            lock,
+		state:           state,
+		progress:        newProgress(entries, viper.GetBool("verbose")),
+		report:          &InstallReport{Results: []PackageResult{}},
+		failFast:        viper.GetBool("load-fail-fast"),
+		cancelled:       make(chan struct{}),
+		aptSourcesReady: len(c.Apt.Sources) == 0,
+		trackChanges:    trackChanges,
+	}
 
-		if _, err := exec.LookPath(pkgManager); err != nil {
-			// * pip might not be available on the system but pip3 is
-			if pkgManager == "pip" {
-				if _, err := exec.LookPath("pip3"); err != nil {
-					log.Error("pip and pip3 are not available on your system", "package-manager", pkgManager)
-					continue
-				}
-				pkgManager = "pip3"
-			} else {
-				log.Error("package manager not available on your system", "package-manager", pkgManager)
+	logs, err := newRunLogs(viper.GetInt("load-keep-logs"))
+	if err != nil {
+		log.Error("failed to create logs directory, commands output won't be saved", "err", err)
+	} else {
+		for _, j := range i.progress.jobs {
+			f, err := logs.create(j.name)
+			if err != nil {
+				log.Error("failed to create log file", "package-manager", j.name, "err", err)
 				continue
 			}
+			defer f.Close()
+			j.logFile = f
 		}
-		// * for some reason, apt binary is available on darwin. Exclude it to avoid errors
-		if pkgManager == "apt" && runtime.GOOS == "darwin" {
-			log.Error("package manager not available on your system", "package-manager", pkgManager)
-			continue
-		}
+	}
 
-		if len(pkgs) == 0 {
-			fmt.Printf("✔️ nothing to do\n\n")
-			continue
+	done := map[string]chan struct{}{}
+	for _, e := range entries {
+		done[e] = make(chan struct{})
+	}
+
+	log.Info("installing packages", "package-managers", entries)
+	i.progress.start()
+
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func(pkgManager string) {
+			defer wg.Done()
+			defer close(done[pkgManager])
+
+			for _, d := range deps[pkgManager] {
+				<-done[d]
+			}
+
+			if i.isCancelled() {
+				i.report.add(PackageResult{PackageManager: pkgManager, Err: ErrCancelled})
+				i.progress.update(pkgManager, jobSkipped, "cancelled")
+				return
+			}
+
+			i.install(pkgManager)
+		}(e)
+	}
+	wg.Wait()
+
+	i.progress.stop()
+
+	if state != nil {
+		if err := state.write(); err != nil {
+			log.Error("failed to write installed packages state", "err", err)
 		}
+	}
 
-		v := viper.GetBool("verbose")
-		commands := []*exec.Cmd{}
-		// * package managers requiring sudo permission
-		if pkgManager == "apt" || pkgManager == "nala" {
-			commands = append(commands, buildDefaultCommand([]string{"sudo", pkgManager, "install", "-y"}, pkgs, v))
-		} else if pkgManager == "cargo" {
-			commands = buildCargoCommand(pkgs, v)
+	for _, f := range i.report.Failures() {
+		if f.Package == "" {
+			log.Error("installation failed", "package-manager", f.PackageManager, "err", f.Err)
 		} else {
-			commands = append(commands, buildDefaultCommand([]string{pkgManager, "install"}, pkgs, v))
+			log.Error("installation failed", "package-manager", f.PackageManager, "package", f.Package, "err", f.Err)
 		}
+	}
 
-		for i, cmd := range commands {
-			spinner := wow.New(os.Stdout, spin.Get(spin.Dots3), " Installing...")
-			if !v {
-				spinner.Start()
+	// * the output is already printed in verbose mode
+	if !viper.GetBool("verbose") {
+		for _, j := range i.progress.jobs {
+			if j.status != jobFailed || j.logFile == nil {
+				continue
 			}
-			if err := cmd.Run(); err != nil {
-				if v {
-					log.Error(err)
-				} else {
-					msg := fmt.Sprintf(" %s", cmd.Args)
-					if i == len(commands)-1 {
-						msg = fmt.Sprintf("%s\n", msg)
-					}
-					spinner.PersistWith(spin.Spinner{Frames: []string{"❌"}}, msg)
-				}
+
+			tail, err := tailFile(j.logFile.Name(), logsTailLines)
+			if err != nil || tail == "" {
 				continue
 			}
+			fmt.Printf("──── %s output (%s) ────\n%s\n", j.name, j.logFile.Name(), tail)
+		}
+	}
+	if logs != nil {
+		log.Info("commands output saved", "path", logs.dir)
+	}
+
+	return i.changed, i.report.Err()
+}
+
+// resolveDependencies returns the package managers each package manager must wait for.
+//
+// Package managers without "depends-on" entry depend on all previous ones in the installation order.
+// Dependencies excluded from the installation are ignored.
+func resolveDependencies(entries []string, dependsOn map[string][]string) (map[string][]string, error) {
+	included := map[string]bool{}
+	for _, e := range entries {
+		included[e] = true
+	}
+
+	deps := map[string][]string{}
+	for i, e := range entries {
+		declared, ok := dependsOn[e]
+		if !ok {
+			deps[e] = entries[:i]
+			continue
+		}
+
+		deps[e] = []string{}
+		for _, d := range declared {
+			if d == e {
+				return nil, fmt.Errorf("package manager %s depends on itself", e)
+			}
+			if included[d] {
+				deps[e] = append(deps[e], d)
+			}
+		}
+	}
 
-			if !v {
-				// msg := fmt.Sprintf(" %s %s", color.GreenString("Success\t"), cmd.Args)
-				msg := fmt.Sprintf(" %s", cmd.Args)
-				if i == len(commands)-1 {
-					msg = fmt.Sprintf("%s\n", msg)
-				}
-				spinner.PersistWith(spin.Spinner{Frames: []string{"✔️"}}, msg)
+	// * detect cycles with a depth-first search
+	visiting := map[string]bool{}
+	visited := map[string]bool{}
+	var visit func(e string, path []string) error
+	visit = func(e string, path []string) error {
+		if visiting[e] {
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(append(path, e), " -> "))
+		}
+		if visited[e] {
+			return nil
+		}
+
+		visiting[e] = true
+		for _, d := range deps[e] {
+			if err := visit(d, append(path, e)); err != nil {
+				return err
 			}
 		}
+		visiting[e] = false
+		visited[e] = true
+
+		return nil
+	}
+	for _, e := range entries {
+		if err := visit(e, []string{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return deps, nil
+}
+
+// needsSudo returns whether an apt or nala entry will run, that is its binary is available
+// and it has packages or apt sources to install
+func needsSudo(c configuration.PkgManagers, entries []string) bool {
+	for _, e := range entries {
+		if e != "apt" && e != "nala" {
+			continue
+		}
+		if _, err := resolveBinary(e); err != nil {
+			continue
+		}
+
+		declared, err := declaredPackages(c, e)
+		if err == nil && (len(declared) > 0 || len(c.Apt.Sources) > 0) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sudoValidate asks for the sudo password if needed so that next sudo commands don't prompt
+func sudoValidate() error {
+	cmd := exec.Command("sudo", "-v")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// installer installs packages of each package manager, possibly at the same time
+type installer struct {
+	config   configuration.PkgManagers
+	lock     Lockfile
+	state    *installState
+	progress *progress
+	// sudoMu prevents package managers using sudo (and dpkg locks) to run at the same time
+	sudoMu          sync.Mutex
+	aptSourcesReady bool
+	report          *InstallReport
+	// failFast cancels next installations after the first failure
+	failFast   bool
+	cancelled  chan struct{}
+	cancelOnce sync.Once
+	// trackChanges compares installed packages of all package managers, not only those with "on-change" hooks
+	trackChanges bool
+	changedMu    sync.Mutex
+	changed      bool
+}
+
+// fail reports a package manager failure unrelated to a specific package
+func (i *installer) fail(pkgManager string, err error) {
+	i.report.add(PackageResult{PackageManager: pkgManager, Err: err})
+	i.progress.update(pkgManager, jobFailed, "%v", err)
+	i.cancel()
+}
+
+// cancel prevents next installations from running in fail-fast mode
+func (i *installer) cancel() {
+	if !i.failFast {
+		return
+	}
+
+	i.cancelOnce.Do(func() {
+		close(i.cancelled)
+	})
+}
+
+func (i *installer) isCancelled() bool {
+	select {
+	case <-i.cancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// install runs the hooks of a package manager around its installation
+func (i *installer) install(pkgManager string) {
+	j := i.progress.job(pkgManager)
+	hooks := i.config.Hooks[pkgManager]
+	env := []string{
+		"CONFIG_MAPPER_ACTION=load",
+		fmt.Sprintf("CONFIG_MAPPER_PACKAGE_MANAGER=%s", pkgManager),
+	}
+
+	i.progress.update(pkgManager, jobRunning, "running pre-load hooks")
+	if err := runHooks(hooks, configuration.HookPreLoad, env, j); err != nil {
+		i.fail(pkgManager, err)
+		return
+	}
+
+	// * toolchains can't be listed, their hooks always run
+	var before map[string]string
+	track := pkgManager != toolchainsEntry && (i.trackChanges || len(hooks.OnChange) > 0)
+	if track {
+		var err error
+		if before, err = i.installedVersions(pkgManager); err != nil {
+			fmt.Fprintf(j, "failed to list installed packages, \"on-change\" hooks are ignored: %v\n", err)
+			track = false
+		}
 	}
 
-	return nil
+	i.installPackages(pkgManager, j)
+	if j.status == jobFailed || j.status == jobSkipped {
+		return
+	}
+	message := j.message
+
+	changed := pkgManager == toolchainsEntry
+	if track {
+		after, err := i.installedVersions(pkgManager)
+		if err != nil {
+			fmt.Fprintf(j, "failed to list installed packages, \"on-change\" hooks are ignored: %v\n", err)
+		} else if pkgs := changedPackages(before, after); len(pkgs) > 0 {
+			changed = true
+			env = append(env, fmt.Sprintf("CONFIG_MAPPER_CHANGED_PACKAGES=%s", strings.Join(pkgs, "\n")))
+		}
+	}
+	if changed {
+		i.changedMu.Lock()
+		i.changed = true
+		i.changedMu.Unlock()
+	}
+
+	i.progress.update(pkgManager, jobRunning, "running post-load hooks")
+	if err := runHooks(hooks, configuration.HookPostLoad, env, j); err != nil {
+		i.fail(pkgManager, err)
+		return
+	}
+	if changed {
+		if err := runHooks(hooks, configuration.HookOnChange, env, j); err != nil {
+			i.fail(pkgManager, err)
+			return
+		}
+	}
+
+	i.progress.update(pkgManager, jobDone, "%s", message)
+}
+
+// changedPackages returns packages installed, upgraded or removed between two listings
+func changedPackages(before, after map[string]string) []string {
+	changed := map[string]bool{}
+	for name, version := range after {
+		if v, ok := before[name]; !ok || v != version {
+			changed[name] = true
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed[name] = true
+		}
+	}
+
+	return sortedKeys(changed)
+}
+
+// installedVersions lists the installed packages of a package manager
+func (i *installer) installedVersions(pkgManager string) (map[string]string, error) {
+	bin, err := resolveBinary(pkgManager)
+	if err != nil {
+		return nil, err
+	}
+
+	return installedVersions(pkgManager, bin)
 }
 
-func buildCargoCommand(packages []string, verbose bool) []*exec.Cmd {
-	commands := []*exec.Cmd{}
+// installPackages installs packages of a package manager and reports its status to the progress display
+func (i *installer) installPackages(pkgManager string, j *job) {
+	i.progress.update(pkgManager, jobRunning, "preparing")
+
+	if pkgManager == toolchainsEntry {
+		i.installToolchains(j)
+		return
+	}
+
+	declared, err := declaredPackages(i.config, pkgManager)
+	if err != nil {
+		i.fail(pkgManager, err)
+		return
+	}
+	if i.lock != nil && len(declared) > 0 {
+		declared = i.lock.apply(pkgManager, declared, j)
+	}
+
+	bin, err := resolveBinary(pkgManager)
+	if err != nil {
+		i.fail(pkgManager, err)
+		return
+	}
 
-	cmd := exec.Command("cargo", "install")
+	// * PEP 668: pip refuses to install packages in a python environment managed by the system
+	stateName := pkgManager
+	if pkgManager == "pip" && externallyManaged() {
+		if _, err := resolveBinary("pipx"); err != nil {
+			fmt.Fprintln(j, "declare your packages with the \"pipx\" or \"uv\" package managers")
+			i.fail(pkgManager, ErrExternallyManaged)
+			return
+		}
+
+		fmt.Fprintln(j, "python environment is externally managed, packages are installed with pipx instead")
+		stateName, bin = "pipx", "pipx"
+	}
+
+	if bin == "apt" || bin == "nala" {
+		i.sudoMu.Lock()
+		defer i.sudoMu.Unlock()
+
+		// * nala relies on apt sources as well
+		if !i.aptSourcesReady {
+			i.progress.update(pkgManager, jobRunning, "updating apt sources")
+			if err := setupAptSources(i.config.Apt.Sources, viper.GetString("storage.location"), j); err != nil {
+				i.fail(pkgManager, fmt.Errorf("failed to setup apt sources: %w", err))
+				return
+			}
+			i.aptSourcesReady = true
+		}
+	}
+
+	if len(declared) == 0 && (pkgManager != "brew" || len(i.config.Brew.Taps) == 0) {
+		i.progress.update(pkgManager, jobDone, "nothing to do")
+		return
+	}
+
+	commands := []*installCommand{}
+	// * package managers requiring sudo permission
+	if bin == "apt" || bin == "nala" {
+		commands = append(commands, buildDefaultCommand(stateName, []string{"sudo", bin, "install", "-y"}, declared, j))
+	} else if bin == "brew" {
+		commands = buildBrewCommands(i.config.Brew, j)
+	} else if bin == "cargo" {
+		commands = buildCargoCommand(declared, j)
+	} else if bin == "uv" {
+		// * "uv tool install" only accepts a single package
+		for _, p := range declared {
+			commands = append(commands, buildDefaultCommand(stateName, []string{bin, "tool", "install"}, []configuration.Package{p}, j))
+		}
+	} else if bin == "nix" {
+		commands = buildNixCommand(bin, declared, j)
+	} else if bin == "pnpm" {
+		commands = append(commands, buildDefaultCommand(stateName, []string{bin, "add", "--global"}, declared, j))
+	} else if bin == "yarn" {
+		commands = append(commands, buildDefaultCommand(stateName, []string{bin, "global", "add"}, declared, j))
+	} else {
+		commands = append(commands, buildDefaultCommand(stateName, []string{bin, "install"}, declared, j))
+	}
+
+	i.runCommands(pkgManager, stateName, commands)
+}
+
+// installToolchains installs language runtimes and makes them available to next package managers
+func (i *installer) installToolchains(j *job) {
+	toolchains, errs := resolveToolchains(i.config.Toolchains)
+	for _, err := range errs {
+		fmt.Fprintln(j, err)
+		i.report.add(PackageResult{PackageManager: toolchainsEntry, Err: err})
+	}
+	if len(errs) > 0 {
+		i.cancel()
+	}
+	if len(toolchains) == 0 && len(errs) == 0 {
+		i.progress.update(toolchainsEntry, jobDone, "nothing to do")
+		return
+	}
+
+	i.runCommands(toolchainsEntry, toolchainsEntry, buildToolchainCommands(toolchains, j))
+
+	// * next package managers must use the installed runtimes
+	paths := []string{}
+	for _, t := range toolchains {
+		if dir := toolchainBinDir(t); dir != "" {
+			paths = append(paths, dir)
+		}
+	}
+	prependPath(paths)
+
+	if len(errs) > 0 {
+		i.progress.update(toolchainsEntry, jobFailed, "%d runtime(s) couldn't be installed", len(errs))
+	}
+}
+
+// runCommands runs installation commands of a package manager, records installed packages
+// and reports the result of each package
+func (i *installer) runCommands(pkgManager, stateName string, commands []*installCommand) {
+	// * packages already installed aren't recorded, prune must not remove packages config-mapper didn't install
+	var before map[string]string
+	if i.state != nil && stateName != toolchainsEntry && len(commands) > 0 {
+		var err error
+		if before, err = i.installedVersions(stateName); err != nil {
+			fmt.Fprintf(commands[0].Stdout, "failed to list installed packages, installed packages won't be recorded: %v\n", err)
+		}
+	}
+
+	failed := 0
+	for n, cmd := range commands {
+		if i.isCancelled() {
+			i.report.add(cmd.results(pkgManager, ErrCancelled)...)
+			failed++
+			continue
+		}
+
+		i.progress.update(pkgManager, jobRunning, "(%d/%d) %s", n+1, len(commands), strings.Join(cmd.Args, " "))
+		fmt.Fprintf(cmd.Stdout, "$ %s\n", strings.Join(cmd.Args, " "))
+
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(cmd.Stdout, "command failed: %v\n", err)
+			i.report.add(cmd.results(pkgManager, err)...)
+			failed++
+			i.cancel()
+			continue
+		}
+
+		i.report.add(cmd.results(pkgManager, nil)...)
+		if i.state != nil {
+			i.recordInstalled(stateName, cmd, before)
+		}
+	}
+
+	if failed > 0 {
+		i.progress.update(pkgManager, jobFailed, "%d/%d command(s) failed", failed, len(commands))
+		return
+	}
+	i.progress.update(pkgManager, jobDone, "%d command(s) succeeded", len(commands))
+}
+
+// recordInstalled records the packages of a command which were absent before the installation and are now installed.
+// Toolchains are always recorded, their plugins aren't listed by installedVersions.
+func (i *installer) recordInstalled(stateName string, cmd *installCommand, before map[string]string) {
+	if stateName == toolchainsEntry {
+		i.state.add(stateName, cmd.packages)
+		return
+	}
+	if before == nil {
+		return
+	}
+
+	after, err := i.installedVersions(stateName)
+	if err != nil {
+		fmt.Fprintf(cmd.Stdout, "failed to list installed packages, installed packages won't be recorded: %v\n", err)
+		return
+	}
+
+	installed := []string{}
+	for _, p := range cmd.packages {
+		if _, ok := lookupVersion(before, stateName, p); ok {
+			continue
+		}
+		if _, ok := lookupVersion(after, stateName, p); ok {
+			installed = append(installed, p)
+		}
+	}
+	i.state.add(stateName, installed)
+}
+
+// declaredPackages returns the packages declared in the configuration for a package manager
+func declaredPackages(c configuration.PkgManagers, pkgManager string) ([]configuration.Package, error) {
+	switch pkgManager {
+	case "brew":
+		return append(append([]configuration.Package{}, c.Brew.Formulas...), c.Brew.Casks...), nil
+	case "apt":
+		return c.Apt.Packages, nil
+	case "cargo":
+		return c.Cargo, nil
+	case "npm":
+		return c.Npm, nil
+	case "pip":
+		return c.Pip, nil
+	case "go":
+		return c.Go, nil
+	case "nala":
+		return c.Nala, nil
+	case "pipx":
+		return c.Pipx, nil
+	case "uv":
+		return c.Uv, nil
+	case "pnpm":
+		return c.Pnpm, nil
+	case "yarn":
+		return c.Yarn, nil
+	case "nix":
+		// * flake references aren't versioned, "@" is part of the reference (E.g: "git+ssh://git@host/repo")
+		refs := make([]configuration.Package, len(c.Nix))
+		for i, p := range c.Nix {
+			refs[i] = configuration.Package{Name: p.String()}
+		}
+
+		return refs, nil
+	default:
+		return nil, ErrPkgManagerUnsupported
+	}
+}
+
+// resolveBinary returns the binary to use for a package manager available on the system
+func resolveBinary(pkgManager string) (string, error) {
+	if _, err := exec.LookPath(pkgManager); err != nil {
+		// * pip might not be available on the system but pip3 is
+		if pkgManager != "pip" {
+			return "", ErrPkgManagerNotAvailable
+		}
+		if _, err := exec.LookPath("pip3"); err != nil {
+			return "", ErrPipNotAvailable
+		}
+
+		return "pip3", nil
+	}
+	// * for some reason, apt binary is available on darwin. Exclude it to avoid errors
+	if pkgManager == "apt" && runtime.GOOS == "darwin" {
+		return "", ErrPkgManagerNotAvailable
+	}
+
+	return pkgManager, nil
+}
+
+// externallyManaged checks if the system python environment is marked as externally managed (PEP 668).
+//
+// Virtual environments are never externally managed.
+func externallyManaged() bool {
+	if os.Getenv("VIRTUAL_ENV") != "" {
+		return false
+	}
+
+	for _, python := range []string{"python3", "python"} {
+		out, err := exec.Command(python, "-c", "import sysconfig; print(sysconfig.get_path('stdlib'))").Output()
+		if err != nil {
+			continue
+		}
+
+		_, err = os.Stat(path.Join(strings.TrimSpace(string(out)), "EXTERNALLY-MANAGED"))
+		return err == nil
+	}
+
+	return false
+}
+
+// pinPackage returns the package argument understood by the package manager, including its version if any
+func pinPackage(pkgManager string, p configuration.Package) string {
+	if p.Version == "" {
+		return p.Name
+	}
+
+	switch pkgManager {
+	case "apt", "nala":
+		return fmt.Sprintf("%s=%s", p.Name, p.Version)
+	case "pip", "pipx", "uv":
+		return fmt.Sprintf("%s==%s", p.Name, p.Version)
+	default:
+		// * brew uses "@" for versioned formulae (E.g: python@3.11)
+		return fmt.Sprintf("%s@%s", p.Name, p.Version)
+	}
+}
+
+// installCommand is a package manager command installing a set of packages
+type installCommand struct {
+	*exec.Cmd
+	// packages holds installed package names
+	packages []string
+}
+
+// results returns the result of each package installed by the command
+func (c *installCommand) results(pkgManager string, err error) []PackageResult {
+	if len(c.packages) == 0 {
+		return []PackageResult{{PackageManager: pkgManager, Command: c.Args, Err: err}}
+	}
+
+	results := []PackageResult{}
+	for _, p := range c.packages {
+		results = append(results, PackageResult{PackageManager: pkgManager, Package: p, Command: c.Args, Err: err})
+	}
+
+	return results
+}
+
+// buildBrewCommands returns commands adding taps, then installing formulae and finally casks
+func buildBrewCommands(brew configuration.Brew, out io.Writer) []*installCommand {
+	commands := []*installCommand{}
+
+	for _, tap := range brew.Taps {
+		cmd := exec.Command("brew", "tap", tap.Name)
+		if tap.URL != "" {
+			cmd.Args = append(cmd.Args, tap.URL)
+		}
+		cmd.Stdout, cmd.Stderr = out, out
+		commands = append(commands, &installCommand{Cmd: cmd, packages: []string{}})
+	}
+
+	// * formulae with options are installed on their own to not apply options to other formulae
+	formulas := []configuration.Package{}
+	for _, f := range brew.Formulas {
+		if len(f.Options) == 0 {
+			formulas = append(formulas, f)
+			continue
+		}
+
+		commands = append(commands, buildDefaultCommand("brew", append([]string{"brew", "install"}, f.Options...), []configuration.Package{f}, out))
+	}
+	if len(formulas) > 0 {
+		commands = append(commands, buildDefaultCommand("brew", []string{"brew", "install"}, formulas, out))
+	}
+
+	if len(brew.Casks) > 0 {
+		commands = append(commands, buildDefaultCommand("brew", []string{"brew", "install", "--cask"}, brew.Casks, out))
+	}
+
+	return commands
+}
+
+func buildCargoCommand(packages []configuration.Package, out io.Writer) []*installCommand {
+	commands := []*installCommand{}
+
+	cmd := &installCommand{Cmd: exec.Command("cargo", "install"), packages: []string{}}
 	for _, pkg := range packages {
-		if strings.Contains(pkg, " ") {
+		p := pinPackage("cargo", pkg)
+		if strings.Contains(p, " ") {
 			customCmd := exec.Command("cargo", "install")
-			customCmd.Args = append(cmd.Args, strings.Split(pkg, " ")...)
-			if verbose {
-				customCmd.Stderr = os.Stderr
-				customCmd.Stdout = os.Stdout
-			}
-			commands = append(commands, customCmd)
+			customCmd.Args = append(customCmd.Args, strings.Split(p, " ")...)
+			customCmd.Stdout, customCmd.Stderr = out, out
+			commands = append(commands, &installCommand{Cmd: customCmd, packages: []string{packageName(pkg)}})
 		} else {
-			cmd.Args = append(cmd.Args, pkg)
+			cmd.Args = append(cmd.Args, p)
+			cmd.packages = append(cmd.packages, packageName(pkg))
 		}
 	}
 
-	if verbose {
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
-	}
+	cmd.Stdout, cmd.Stderr = out, out
 	if len(cmd.Args) > 2 {
 		commands = append(commands, cmd)
 	}
@@ -145,13 +755,22 @@ func buildCargoCommand(packages []string, verbose bool) []*exec.Cmd {
 	return commands
 }
 
-func buildDefaultCommand(command, packages []string, verbose bool) *exec.Cmd {
-	cmd := exec.Command(command[0], command[1:]...)
-	cmd.Args = append(cmd.Args, packages...)
-	if verbose {
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
+func buildDefaultCommand(pkgManager string, command []string, packages []configuration.Package, out io.Writer) *installCommand {
+	cmd := &installCommand{Cmd: exec.Command(command[0], command[1:]...), packages: []string{}}
+	for _, p := range packages {
+		cmd.Args = append(cmd.Args, pinPackage(pkgManager, p))
+		cmd.packages = append(cmd.packages, packageName(p))
 	}
+	cmd.Stdout, cmd.Stderr = out, out
 
 	return cmd
 }
+
+// packageName returns the package name without its additional arguments
+func packageName(p configuration.Package) string {
+	if fields := strings.Fields(p.Name); len(fields) > 0 {
+		return fields[0]
+	}
+
+	return p.Name
+}
@@ -0,0 +1,101 @@
+package mapper
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+)
+
+func TestPinPackage(t *testing.T) {
+	for _, tc := range []struct {
+		pkgManager string
+		pkg        configuration.Package
+		want       string
+	}{
+		{"apt", configuration.Package{Name: "curl"}, "curl"},
+		{"apt", configuration.Package{Name: "curl", Version: "7.88.1-10"}, "curl=7.88.1-10"},
+		{"nala", configuration.Package{Name: "curl", Version: "7.88.1-10"}, "curl=7.88.1-10"},
+		{"pip", configuration.Package{Name: "requests", Version: "2.28.1"}, "requests==2.28.1"},
+		{"pipx", configuration.Package{Name: "black", Version: "23.1.0"}, "black==23.1.0"},
+		{"uv", configuration.Package{Name: "ruff", Version: "0.1.0"}, "ruff==0.1.0"},
+		{"npm", configuration.Package{Name: "@angular/cli", Version: "15.0.0"}, "@angular/cli@15.0.0"},
+		{"cargo", configuration.Package{Name: "ripgrep", Version: "13.0.0"}, "ripgrep@13.0.0"},
+		{"go", configuration.Package{Name: "golang.org/x/tools/gopls", Version: "latest"}, "golang.org/x/tools/gopls@latest"},
+		// * versioned formulae are a name, they're never pinned
+		{"brew", configuration.Package{Name: "python@3.11"}, "python@3.11"},
+	} {
+		t.Run(tc.pkgManager+"/"+tc.want, func(t *testing.T) {
+			if got := pinPackage(tc.pkgManager, tc.pkg); got != tc.want {
+				t.Errorf("pinPackage(%s, %+v) = %q, want %q", tc.pkgManager, tc.pkg, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveDependencies(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		entries   []string
+		dependsOn map[string][]string
+		want      map[string][]string
+		err       error
+	}{
+		{
+			name:    "installation order",
+			entries: []string{"apt", "cargo", "npm"},
+			want:    map[string][]string{"apt": {}, "cargo": {"apt"}, "npm": {"apt", "cargo"}},
+		},
+		{
+			name:      "declared dependencies",
+			entries:   []string{"apt", "brew", "cargo", "npm"},
+			dependsOn: map[string][]string{"brew": {}, "cargo": {"apt"}, "npm": {"brew"}},
+			want:      map[string][]string{"apt": {}, "brew": {}, "cargo": {"apt"}, "npm": {"brew"}},
+		},
+		{
+			name:      "excluded dependency",
+			entries:   []string{"cargo", "npm"},
+			dependsOn: map[string][]string{"npm": {"toolchains", "cargo"}},
+			want:      map[string][]string{"cargo": {}, "npm": {"cargo"}},
+		},
+		{
+			name:      "cycle",
+			entries:   []string{"cargo", "npm"},
+			dependsOn: map[string][]string{"cargo": {"npm"}, "npm": {"cargo"}},
+			err:       ErrDependencyCycle,
+		},
+		{
+			name:      "cycle through the installation order",
+			entries:   []string{"cargo", "npm"},
+			dependsOn: map[string][]string{"cargo": {"npm"}},
+			err:       ErrDependencyCycle,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := resolveDependencies(tc.entries, tc.dependsOn)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("resolveDependencies() error = %v, want %v", err, tc.err)
+			}
+			if tc.err == nil && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("resolveDependencies() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := resolveDependencies([]string{"npm"}, map[string][]string{"npm": {"npm"}}); err == nil {
+		t.Error("expected an error for a package manager depending on itself")
+	}
+}
+
+func TestChangedPackages(t *testing.T) {
+	before := map[string]string{"bat": "0.22.1", "ripgrep": "13.0.0", "fd": "8.7.0"}
+	after := map[string]string{"bat": "0.23.0", "ripgrep": "13.0.0", "exa": "0.10.1"}
+
+	if got, want := changedPackages(before, after), []string{"bat", "exa", "fd"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changedPackages() = %v, want %v", got, want)
+	}
+	if got := changedPackages(before, before); len(got) != 0 {
+		t.Errorf("changedPackages() = %v for the same packages", got)
+	}
+}
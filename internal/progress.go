@@ -0,0 +1,179 @@
+package mapper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+type jobStatus int
+
+const (
+	jobWaiting jobStatus = iota
+	jobRunning
+	jobDone
+	jobFailed
+	jobSkipped
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// job is a package manager installation tracked by the progress display
+type job struct {
+	name    string
+	status  jobStatus
+	message string
+	// output holds the package manager commands output
+	output bytes.Buffer
+	// logFile receives a copy of the output if set
+	logFile *os.File
+}
+
+// Write appends to the job output and its log file. Commands of a job run one after the other.
+func (j *job) Write(p []byte) (int, error) {
+	if j.logFile != nil {
+		if _, err := j.logFile.Write(p); err != nil {
+			return 0, err
+		}
+	}
+
+	return j.output.Write(p)
+}
+
+// progress displays the status of all package managers at once.
+//
+// On a terminal and without the verbose mode, lines are redrawn in place.
+// Otherwise, a line is printed on each status change and, in verbose mode, the job output once it's finished.
+type progress struct {
+	mu      sync.Mutex
+	jobs    []*job
+	out     io.Writer
+	verbose bool
+	live    bool
+	width   int
+	frame   int
+	drawn   bool
+	stopped chan struct{}
+	ticker  *time.Ticker
+}
+
+func newProgress(names []string, verbose bool) *progress {
+	p := &progress{
+		jobs:    []*job{},
+		out:     os.Stdout,
+		verbose: verbose,
+		live:    !verbose && isatty.IsTerminal(os.Stdout.Fd()),
+		stopped: make(chan struct{}),
+	}
+	for _, n := range names {
+		p.jobs = append(p.jobs, &job{name: n, message: "waiting"})
+		if len(n) > p.width {
+			p.width = len(n)
+		}
+	}
+
+	return p
+}
+
+// job returns the job of a package manager
+func (p *progress) job(name string) *job {
+	for _, j := range p.jobs {
+		if j.name == name {
+			return j
+		}
+	}
+
+	return nil
+}
+
+func (p *progress) start() {
+	if !p.live {
+		return
+	}
+
+	p.ticker = time.NewTicker(100 * time.Millisecond)
+	go func() {
+		for {
+			select {
+			case <-p.ticker.C:
+				p.mu.Lock()
+				p.frame++
+				p.draw()
+				p.mu.Unlock()
+			case <-p.stopped:
+				return
+			}
+		}
+	}()
+}
+
+func (p *progress) stop() {
+	if !p.live {
+		return
+	}
+
+	p.ticker.Stop()
+	close(p.stopped)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.draw()
+}
+
+// update changes the status of a job
+func (p *progress) update(name string, status jobStatus, format string, args ...interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	j := p.job(name)
+	j.status = status
+	j.message = fmt.Sprintf(format, args...)
+
+	if p.live {
+		p.draw()
+		return
+	}
+
+	fmt.Fprintln(p.out, p.line(j))
+	if p.verbose && status != jobWaiting && status != jobRunning && j.output.Len() > 0 {
+		fmt.Fprintf(p.out, "──── %s output ────\n%s\n", j.name, strings.TrimRight(j.output.String(), "\n"))
+	}
+}
+
+// draw redraws all jobs lines in place. Must be called with the lock held.
+func (p *progress) draw() {
+	if p.drawn {
+		fmt.Fprintf(p.out, "\033[%dA", len(p.jobs))
+	}
+	for _, j := range p.jobs {
+		fmt.Fprintf(p.out, "\033[2K%s\n", p.line(j))
+	}
+	p.drawn = true
+}
+
+func (p *progress) line(j *job) string {
+	var icon string
+	switch j.status {
+	case jobWaiting:
+		icon = "⏸"
+	case jobRunning:
+		icon = "▶"
+		if p.live {
+			icon = spinnerFrames[p.frame%len(spinnerFrames)]
+		}
+	case jobDone:
+		icon = "✔️"
+	case jobFailed:
+		icon = "❌"
+	case jobSkipped:
+		icon = "⏭"
+	}
+
+	return fmt.Sprintf("%s %-*s  %s", icon, p.width, j.name, j.message)
+}
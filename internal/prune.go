@@ -0,0 +1,210 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+)
+
+var ErrPruneUnsupported = errors.New("package manager doesn't support pruning")
+
+// PrunePackages uninstalls packages previously installed by config-mapper which are no more declared.
+//
+// Packages not installed by config-mapper (E.g: system base packages) are never removed.
+// The removal plan is printed and requires a confirmation before anything is uninstalled.
+func PrunePackages(c configuration.PkgManagers) error {
+	excluded := map[string]bool{}
+	for _, pkgManager := range viper.GetStringSlice("exclude-pkg-managers") {
+		excluded[pkgManager] = true
+	}
+
+	state, err := readInstallState()
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer
+	if viper.GetBool("verbose") {
+		out = os.Stdout
+	}
+
+	plan := map[string][]*installCommand{}
+	order := []string{}
+	unprunable := []string{}
+	for _, pkgManager := range c.InstallationOrder {
+		if excluded[pkgManager] || len(state.Packages[pkgManager]) == 0 {
+			continue
+		}
+
+		declared, err := declaredPackages(c, pkgManager)
+		if err != nil {
+			log.Error(err, "package-manager", pkgManager)
+			continue
+		}
+
+		bin, err := resolveBinary(pkgManager)
+		if err != nil {
+			log.Error(err, "package-manager", pkgManager)
+			continue
+		}
+
+		installed, err := installedVersions(pkgManager, bin)
+		if err != nil {
+			log.Error("failed to retrieve installed packages", "package-manager", pkgManager, "err", err)
+			continue
+		}
+
+		declaredNames := map[string]bool{}
+		for _, p := range declared {
+			declaredNames[normalizeName(pkgManager, packageName(p))] = true
+		}
+
+		removed := []string{}
+		forgotten := []string{}
+		for _, p := range state.Packages[pkgManager] {
+			if declaredNames[normalizeName(pkgManager, p)] {
+				continue
+			}
+			if _, ok := lookupVersion(installed, pkgManager, p); !ok {
+				// * already uninstalled outside of config-mapper
+				forgotten = append(forgotten, p)
+				continue
+			}
+
+			removed = append(removed, p)
+		}
+		state.remove(pkgManager, forgotten)
+
+		if len(removed) == 0 {
+			continue
+		}
+
+		// * managers without an uninstall command are reported before the confirmation, never after
+		commands, err := buildUninstallCommands(pkgManager, bin, removed, out)
+		if errors.Is(err, ErrPruneUnsupported) {
+			unprunable = append(unprunable, fmt.Sprintf("%s: %s", pkgManager, strings.Join(removed, ", ")))
+			continue
+		}
+		if err != nil {
+			log.Error(err, "package-manager", pkgManager)
+			continue
+		}
+
+		plan[pkgManager] = commands
+		order = append(order, pkgManager)
+	}
+
+	if len(unprunable) > 0 {
+		fmt.Println("The following packages are no more declared but can't be uninstalled by their package manager:")
+		for _, l := range unprunable {
+			fmt.Printf("  %s\n", l)
+		}
+	}
+
+	if len(order) == 0 {
+		log.Info("no package to prune")
+		return state.write()
+	}
+
+	fmt.Println("The following packages will be uninstalled:")
+	for _, pkgManager := range order {
+		pkgs := []string{}
+		for _, cmd := range plan[pkgManager] {
+			pkgs = append(pkgs, cmd.packages...)
+		}
+		fmt.Printf("  %s: %s\n", pkgManager, strings.Join(pkgs, ", "))
+	}
+	if !misc.Confirm("Do you want to continue?") {
+		log.Info("pruning aborted")
+		return state.write()
+	}
+
+	for _, pkgManager := range order {
+		log.Info("uninstalling packages", "package-manager", pkgManager)
+		for _, cmd := range plan[pkgManager] {
+			if err := cmd.Run(); err != nil {
+				log.Error("failed to uninstall packages", "package-manager", pkgManager, "packages", cmd.packages, "err", err)
+				continue
+			}
+
+			state.remove(pkgManager, cmd.packages)
+		}
+	}
+
+	return state.write()
+}
+
+func buildUninstallCommands(pkgManager, bin string, pkgs []string, out io.Writer) ([]*installCommand, error) {
+	var command []string
+	switch pkgManager {
+	case "brew":
+		command = []string{bin, "uninstall"}
+	case "apt", "nala":
+		command = []string{"sudo", bin, "remove", "-y"}
+	case "cargo":
+		command = []string{bin, "uninstall"}
+	case "pip":
+		command = []string{bin, "uninstall", "-y"}
+	case "npm":
+		command = []string{bin, "uninstall", "--global"}
+	case "pipx":
+		command = []string{bin, "uninstall"}
+	case "uv":
+		command = []string{bin, "tool", "uninstall"}
+	case "pnpm":
+		command = []string{bin, "remove", "--global"}
+	case "yarn":
+		command = []string{bin, "global", "remove"}
+	case "nix":
+		command = append(append([]string{bin}, nixFeatures...), "profile", "remove")
+	default:
+		return nil, ErrPruneUnsupported
+	}
+
+	// * "pipx uninstall" only accepts a single package
+	groups := [][]string{pkgs}
+	if pkgManager == "pipx" {
+		groups = [][]string{}
+		for _, p := range pkgs {
+			groups = append(groups, []string{p})
+		}
+	}
+
+	commands := []*installCommand{}
+	for _, g := range groups {
+		args := g
+		// * profile elements are removed by name
+		if pkgManager == "nix" {
+			args = make([]string, len(g))
+			for i, ref := range g {
+				args[i] = strings.SplitN(normalizeNixRef(ref), "#", 2)[1]
+			}
+		}
+
+		cmd := exec.Command(command[0], append(command[1:], args...)...)
+		cmd.Stdout, cmd.Stderr = out, out
+		commands = append(commands, &installCommand{Cmd: cmd, packages: g})
+	}
+
+	return commands, nil
+}
+
+// normalizeName returns a package name comparable with other names of the package manager
+func normalizeName(pkgManager, name string) string {
+	switch pkgManager {
+	case "pip", "pipx", "uv":
+		return strings.ToLower(name)
+	case "nix":
+		return normalizeNixRef(name)
+	}
+
+	return name
+}
@@ -0,0 +1,26 @@
+package mapper
+
+import "testing"
+
+func TestNormalizeName(t *testing.T) {
+	for _, tc := range []struct {
+		pkgManager string
+		name       string
+		want       string
+	}{
+		{"pip", "Requests", "requests"},
+		{"pipx", "Black", "black"},
+		{"uv", "Ruff", "ruff"},
+		{"npm", "Typescript", "Typescript"},
+		{"brew", "python@3.11", "python@3.11"},
+		{"nix", "nixpkgs#ripgrep", "nixpkgs#ripgrep"},
+		{"nix", "flake:nixpkgs#legacyPackages.x86_64-linux.ripgrep", "nixpkgs#ripgrep"},
+		{"nix", "github:owner/repo", "github:owner/repo#default"},
+	} {
+		t.Run(tc.pkgManager+"/"+tc.name, func(t *testing.T) {
+			if got := normalizeName(tc.pkgManager, tc.name); got != tc.want {
+				t.Errorf("normalizeName(%s, %q) = %q, want %q", tc.pkgManager, tc.name, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var ErrCancelled = errors.New("cancelled after a previous failure (--fail-fast)")
+
+// PackageResult is the installation result of a package
+type PackageResult struct {
+	PackageManager string
+	// Package is empty when the result isn't related to a specific package
+	// (E.g: package manager not available, tap added)
+	Package string
+	Command []string
+	Err     error
+}
+
+// InstallReport collects installation results of all package managers
+type InstallReport struct {
+	mu      sync.Mutex
+	Results []PackageResult
+}
+
+func (r *InstallReport) add(results ...PackageResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Results = append(r.Results, results...)
+}
+
+// Failures returns results of packages which failed to install
+func (r *InstallReport) Failures() []PackageResult {
+	failures := []PackageResult{}
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failures = append(failures, res)
+		}
+	}
+
+	return failures
+}
+
+// Err returns an InstallError if any package failed to install
+func (r *InstallReport) Err() error {
+	if len(r.Failures()) == 0 {
+		return nil
+	}
+
+	return &InstallError{Report: r}
+}
+
+// InstallError is returned when at least one package failed to install
+type InstallError struct {
+	Report *InstallReport
+}
+
+func (e *InstallError) Error() string {
+	failures := []string{}
+	for _, f := range e.Report.Failures() {
+		if f.Package == "" {
+			failures = append(failures, fmt.Sprintf("%s: %v", f.PackageManager, f.Err))
+		} else {
+			failures = append(failures, fmt.Sprintf("%s: %s: %v", f.PackageManager, f.Package, f.Err))
+		}
+	}
+
+	return fmt.Sprintf("failed to install packages: %s", strings.Join(failures, "; "))
+}
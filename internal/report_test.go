@@ -0,0 +1,34 @@
+package mapper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstallReport(t *testing.T) {
+	r := &InstallReport{Results: []PackageResult{}}
+	if err := r.Err(); err != nil {
+		t.Fatalf("Err() = %v for an empty report", err)
+	}
+
+	failure := errors.New("exit status 1")
+	r.add(
+		PackageResult{PackageManager: "cargo", Package: "ripgrep"},
+		PackageResult{PackageManager: "cargo", Package: "bat", Err: failure},
+	)
+	r.add(PackageResult{PackageManager: "npm", Err: ErrPkgManagerNotAvailable})
+
+	if failures := r.Failures(); len(failures) != 2 {
+		t.Errorf("Failures() returned %d results, want 2", len(failures))
+	}
+
+	err := r.Err()
+	var installErr *InstallError
+	if !errors.As(err, &installErr) {
+		t.Fatalf("Err() = %v, want an InstallError", err)
+	}
+	want := "failed to install packages: cargo: bat: exit status 1; npm: " + ErrPkgManagerNotAvailable.Error()
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
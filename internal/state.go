@@ -0,0 +1,105 @@
+package mapper
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/misc"
+	"gopkg.in/yaml.v2"
+)
+
+const installStateName = "installed.yml"
+
+// installState records packages installed by config-mapper on the current system.
+//
+// It's kept outside of the storage location since it's specific to each system.
+type installState struct {
+	mu       sync.Mutex
+	path     string
+	Packages map[string][]string `yaml:"packages"`
+}
+
+func readInstallState() (*installState, error) {
+	p, err := misc.StatePath(installStateName)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &installState{
+		path:     p,
+		Packages: map[string][]string{},
+	}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	if s.Packages == nil {
+		s.Packages = map[string][]string{}
+	}
+
+	return s, nil
+}
+
+// add records packages as installed by config-mapper
+func (s *installState) add(pkgManager string, pkgs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recorded := map[string]bool{}
+	for _, p := range s.Packages[pkgManager] {
+		recorded[p] = true
+	}
+	for _, p := range pkgs {
+		recorded[p] = true
+	}
+
+	s.Packages[pkgManager] = sortedKeys(recorded)
+}
+
+// remove forgets packages previously installed by config-mapper
+func (s *installState) remove(pkgManager string, pkgs []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := map[string]bool{}
+	for _, p := range pkgs {
+		removed[p] = true
+	}
+
+	kept := []string{}
+	for _, p := range s.Packages[pkgManager] {
+		if !removed[p] {
+			kept = append(kept, p)
+		}
+	}
+
+	s.Packages[pkgManager] = kept
+}
+
+func (s *installState) write() error {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.path, b, 0644)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
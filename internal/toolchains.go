@@ -0,0 +1,280 @@
+package mapper
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gitea.antoine-langlois.net/datahearth/config-mapper/internal/configuration"
+)
+
+// toolchainsEntry is the "installation-order" entry installing language runtimes
+const toolchainsEntry = "toolchains"
+
+var ErrNoVersionManager = errors.New("no version manager available on your system (mise, asdf, rustup, pyenv, nvm)")
+
+// asdfPlugins maps runtimes to their asdf plugin name
+var asdfPlugins = map[string]string{
+	"go":     "golang",
+	"node":   "nodejs",
+	"python": "python",
+	"rust":   "rust",
+}
+
+// asdfVersion matches the version printed by "asdf --version" (E.g: "v0.14.1-ccdd47d", "asdf version 0.16.0")
+var asdfVersion = regexp.MustCompile(`(\d+)\.(\d+)\.\d+`)
+
+// toolchain is a runtime version with its resolved version manager
+type toolchain struct {
+	runtime string
+	version string
+	manager string
+}
+
+// resolveToolchains returns declared runtimes with the version manager installing them
+// and errors for runtimes without an available version manager
+func resolveToolchains(t configuration.Toolchains) ([]toolchain, []error) {
+	toolchains := []toolchain{}
+	errs := []error{}
+	for _, runtime := range []struct {
+		name      string
+		toolchain configuration.Toolchain
+	}{
+		{"go", t.Go},
+		{"node", t.Node},
+		{"python", t.Python},
+		{"rust", t.Rust},
+	} {
+		if runtime.toolchain.Version == "" {
+			continue
+		}
+
+		manager := runtime.toolchain.Manager
+		if manager == "" {
+			manager = t.Manager
+		}
+
+		manager, err := resolveVersionManager(runtime.name, manager)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", runtime.name, err))
+			continue
+		}
+
+		toolchains = append(toolchains, toolchain{runtime: runtime.name, version: runtime.toolchain.Version, manager: manager})
+	}
+
+	return toolchains, errs
+}
+
+// buildToolchainCommands returns commands installing each runtime with its version manager
+func buildToolchainCommands(toolchains []toolchain, out io.Writer) []*installCommand {
+	commands := []*installCommand{}
+	for _, t := range toolchains {
+		for _, args := range toolchainCommands(t) {
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdout, cmd.Stderr = out, out
+			commands = append(commands, &installCommand{Cmd: cmd, packages: []string{}})
+		}
+	}
+
+	return commands
+}
+
+// resolveVersionManager returns the version manager installing a runtime.
+//
+// Without any configured manager, rustup is preferred for rust, pyenv for python and nvm for node.
+// Then, mise and asdf are used.
+func resolveVersionManager(runtime, manager string) (string, error) {
+	if manager != "" {
+		switch manager {
+		case "mise", "asdf":
+		case "rustup":
+			if runtime != "rust" {
+				return "", fmt.Errorf("rustup can only install rust")
+			}
+		case "pyenv":
+			if runtime != "python" {
+				return "", fmt.Errorf("pyenv can only install python")
+			}
+		case "nvm":
+			if runtime != "node" {
+				return "", fmt.Errorf("nvm can only install node")
+			}
+		default:
+			return "", fmt.Errorf("version manager %s not supported", manager)
+		}
+
+		if !versionManagerAvailable(manager) {
+			return "", fmt.Errorf("version manager %s not available on your system", manager)
+		}
+
+		return manager, nil
+	}
+
+	candidates := []string{"mise", "asdf"}
+	switch runtime {
+	case "rust":
+		candidates = append([]string{"rustup"}, candidates...)
+	case "python":
+		candidates = append([]string{"pyenv"}, candidates...)
+	case "node":
+		candidates = append([]string{"nvm"}, candidates...)
+	}
+
+	for _, c := range candidates {
+		if versionManagerAvailable(c) {
+			return c, nil
+		}
+	}
+
+	return "", ErrNoVersionManager
+}
+
+func versionManagerAvailable(manager string) bool {
+	// * nvm is a shell function sourced from "nvm.sh"
+	if manager == "nvm" {
+		_, err := os.Stat(path.Join(nvmDir(), "nvm.sh"))
+		return err == nil
+	}
+
+	_, err := exec.LookPath(manager)
+	return err == nil
+}
+
+// toolchainCommands returns the commands installing a runtime version and setting it as default
+func toolchainCommands(t toolchain) [][]string {
+	switch t.manager {
+	case "mise":
+		return [][]string{{"mise", "use", "--global", fmt.Sprintf("%s@%s", t.runtime, t.version)}}
+	case "asdf":
+		plugin := asdfPlugins[t.runtime]
+		commands := [][]string{}
+		// * "asdf plugin add" fails if the plugin is already added
+		if !asdfPluginAdded(plugin) {
+			commands = append(commands, []string{"asdf", "plugin", "add", plugin})
+		}
+		commands = append(commands, []string{"asdf", "install", plugin, t.version})
+		// * "asdf global" is replaced by "asdf set --home" since asdf 0.16
+		if asdfHasSet() {
+			return append(commands, []string{"asdf", "set", "--home", plugin, t.version})
+		}
+		return append(commands, []string{"asdf", "global", plugin, t.version})
+	case "rustup":
+		return [][]string{
+			{"rustup", "toolchain", "install", t.version},
+			{"rustup", "default", t.version},
+		}
+	case "pyenv":
+		return [][]string{
+			{"pyenv", "install", "--skip-existing", t.version},
+			{"pyenv", "global", t.version},
+		}
+	case "nvm":
+		// * the script path and the version are given as arguments so the shell never interprets them
+		script := `. "$1" && nvm install "$2" && nvm alias default "$2"`
+		return [][]string{{"bash", "-c", script, "nvm", path.Join(nvmDir(), "nvm.sh"), t.version}}
+	default:
+		return nil
+	}
+}
+
+func asdfPluginAdded(plugin string) bool {
+	out, err := exec.Command("asdf", "plugin", "list").Output()
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == plugin {
+			return true
+		}
+	}
+	return false
+}
+
+// asdfHasSet returns whether the installed asdf is 0.16 or later
+func asdfHasSet() bool {
+	out, err := exec.Command("asdf", "--version").Output()
+	if err != nil {
+		return false
+	}
+
+	m := asdfVersion.FindStringSubmatch(string(out))
+	if m == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return major > 0 || minor >= 16
+}
+
+// toolchainBinDir returns the directory containing the runtime binaries (or shims) once installed
+func toolchainBinDir(t toolchain) string {
+	h, _ := os.UserHomeDir()
+
+	switch t.manager {
+	case "mise":
+		if dir := os.Getenv("MISE_DATA_DIR"); dir != "" {
+			return path.Join(dir, "shims")
+		}
+		return path.Join(h, ".local", "share", "mise", "shims")
+	case "asdf":
+		if dir := os.Getenv("ASDF_DATA_DIR"); dir != "" {
+			return path.Join(dir, "shims")
+		}
+		return path.Join(h, ".asdf", "shims")
+	case "rustup":
+		if dir := os.Getenv("CARGO_HOME"); dir != "" {
+			return path.Join(dir, "bin")
+		}
+		return path.Join(h, ".cargo", "bin")
+	case "pyenv":
+		if dir := os.Getenv("PYENV_ROOT"); dir != "" {
+			return path.Join(dir, "shims")
+		}
+		return path.Join(h, ".pyenv", "shims")
+	case "nvm":
+		return nvmBinDir(t.version)
+	default:
+		return ""
+	}
+}
+
+func nvmDir() string {
+	if dir := os.Getenv("NVM_DIR"); dir != "" {
+		return dir
+	}
+
+	h, _ := os.UserHomeDir()
+	return path.Join(h, ".nvm")
+}
+
+// nvmBinDir returns the binaries directory of an installed node version.
+//
+// It's resolved after installation since nvm accepts partial versions and aliases (E.g: "20", "lts/*").
+func nvmBinDir(version string) string {
+	out, err := exec.Command("bash", "-c", `. "$1" && nvm which "$2"`, "nvm", path.Join(nvmDir(), "nvm.sh"), version).Output()
+	if err != nil {
+		return ""
+	}
+
+	return path.Dir(strings.TrimSpace(string(out)))
+}
+
+// prependPath adds directories in front of the PATH so the installed runtimes are used by next package managers
+func prependPath(dirs []string) {
+	if len(dirs) == 0 {
+		return
+	}
+
+	entries := make([]string, 0, len(dirs)+1)
+	entries = append(entries, dirs...)
+	entries = append(entries, os.Getenv("PATH"))
+	os.Setenv("PATH", strings.Join(entries, string(os.PathListSeparator)))
+}
@@ -0,0 +1,104 @@
+package mapper
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToolchainCommands(t *testing.T) {
+	t.Setenv("NVM_DIR", "/nvm")
+
+	for _, tc := range []struct {
+		name string
+		t    toolchain
+		want [][]string
+	}{
+		{
+			name: "mise",
+			t:    toolchain{runtime: "node", version: "20", manager: "mise"},
+			want: [][]string{{"mise", "use", "--global", "node@20"}},
+		},
+		{
+			name: "rustup",
+			t:    toolchain{runtime: "rust", version: "stable", manager: "rustup"},
+			want: [][]string{{"rustup", "toolchain", "install", "stable"}, {"rustup", "default", "stable"}},
+		},
+		{
+			name: "pyenv",
+			t:    toolchain{runtime: "python", version: "3.12", manager: "pyenv"},
+			want: [][]string{{"pyenv", "install", "--skip-existing", "3.12"}, {"pyenv", "global", "3.12"}},
+		},
+		{
+			name: "nvm",
+			t:    toolchain{runtime: "node", version: "lts/*", manager: "nvm"},
+			want: [][]string{{"bash", "-c", `. "$1" && nvm install "$2" && nvm alias default "$2"`, "nvm", "/nvm/nvm.sh", "lts/*"}},
+		},
+		{
+			name: "unknown manager",
+			t:    toolchain{runtime: "node", version: "20", manager: "volta"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := toolchainCommands(tc.t); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("toolchainCommands() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNvmVersionNotInterpreted(t *testing.T) {
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash isn't available")
+	}
+
+	dir := t.TempDir()
+	t.Setenv("NVM_DIR", dir)
+	// * the fake nvm prints its arguments
+	if err := os.WriteFile(path.Join(dir, "nvm.sh"), []byte(`nvm() { echo "$@"; }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	version := `20; touch injected $(touch injected) "`
+	cmd := toolchainCommands(toolchain{runtime: "node", version: version, manager: "nvm"})[0]
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Dir = dir
+	out, err := c.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "install " + version + "\nalias default " + version + "\n"; string(out) != want {
+		t.Errorf("nvm was called with %q, want %q", out, want)
+	}
+	if _, err := os.Stat(path.Join(dir, "injected")); err == nil {
+		t.Error("the version was interpreted by the shell")
+	}
+}
+
+func TestPrependPath(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+
+	backing := make([]string, 1, 2)
+	backing[0] = "/runtime/bin"
+	dirs := backing[:1]
+	after := backing[:2]
+	after[1] = "untouched"
+
+	prependPath(dirs)
+
+	if got, want := os.Getenv("PATH"), strings.Join([]string{"/runtime/bin", "/usr/bin"}, string(os.PathListSeparator)); got != want {
+		t.Errorf("PATH = %q, want %q", got, want)
+	}
+	if after[1] != "untouched" {
+		t.Errorf("prependPath() overwrote the backing array of its argument: %q", after[1])
+	}
+
+	prependPath(nil)
+	if got := os.Getenv("PATH"); !strings.HasSuffix(got, "/usr/bin") || strings.Count(got, string(os.PathListSeparator)) != 1 {
+		t.Errorf("PATH = %q after prepending nothing", got)
+	}
+}